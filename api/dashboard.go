@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	EVENT_POLL_INTERVAL_MS = 200 // samme som STATUS_UPDATE_INTERVAL_MS i requests
+	EVENT_KEEPALIVE_S      = 15
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// sender tilstanden som en "state"-event når den endres. Tilstanden sjekkes hvert EVENT_POLL_INTERVAL_MS,
+// slik at RequestControlLoop ikke må vente på trege klienter
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	pollTicker := time.NewTicker(EVENT_POLL_INTERVAL_MS * time.Millisecond)
+	defer pollTicker.Stop()
+	keepaliveTicker := time.NewTicker(EVENT_KEEPALIVE_S * time.Second)
+	defer keepaliveTicker.Stop()
+
+	var lastSent []byte
+	sendIfChanged := func() error {
+		data, err := json.Marshal(s.currentState())
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(data, lastSent) {
+			return nil
+		}
+		lastSent = data
+		if _, err := fmt.Fprintf(w, "event: state\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	if sendIfChanged() != nil {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-pollTicker.C:
+			if sendIfChanged() != nil {
+				return
+			}
+		case <-keepaliveTicker.C:
+			// kommentar som holder forbindelsen åpen gjennom proxyer
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"project/logging"
+)
+
+// "default" som level for en pakke fjerner overstyringen, slik at pakken følger det felles nivået
+const LOG_LEVEL_DEFAULT = "default"
+
+type logLevelView struct {
+	Level    string            `json:"level"`
+	Packages map[string]string `json:"packages"`
+}
+
+type logLevelRequest struct {
+	Level   string `json:"level"`
+	Package string `json:"package,omitempty"` // tom for det felles nivået
+}
+
+func currentLogLevels() logLevelView {
+	view := logLevelView{Level: logging.GetLevel().String(), Packages: map[string]string{}}
+	for pkg, level := range logging.GetPackageLevels() {
+		view.Packages[pkg] = level.String()
+	}
+	return view
+}
+
+// GET gir nivåene, POST {"level": "debug", "package": "requests"} endrer dem mens noden kjører
+func (s *Server) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, currentLogLevels())
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "use GET or POST")
+		return
+	}
+
+	request := logLevelRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if request.Package != "" && request.Level == LOG_LEVEL_DEFAULT {
+		logging.ClearPackageLevel(request.Package)
+		log.Info("Log level override removed", "package", request.Package)
+		writeJSON(w, http.StatusOK, currentLogLevels())
+		return
+	}
+	level, err := logging.ParseLevel(request.Level)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if request.Package == "" {
+		logging.SetLevel(level)
+	} else {
+		logging.SetPackageLevel(request.Package, level)
+	}
+	log.Info("Log level changed", "package", request.Package, "newLevel", level)
+	writeJSON(w, http.StatusOK, currentLogLevels())
+}
@@ -0,0 +1,209 @@
+package api
+
+// HTTP API for en node: JSON med tilstanden til noden, og POST for å legge inn bestillinger
+//
+//	GET  /api/state          alt under samlet
+//	GET  /api/elevator       lokal heis fra fsm
+//	GET  /api/hall-requests  hallRequests med state, count og awareList
+//	GET  /api/cab-requests   allCabRequests
+//	GET  /api/elevators      updatedInfoElevs
+//	GET  /api/peers          peerList og om noden er koblet til nettverket
+//	GET  /api/assignment     siste bestillinger fra fordelingen til den lokale heisen
+//	POST /api/hall-call      {"floor": 2, "direction": "up"}
+//	POST /api/cab-call       {"floor": 3}
+//	GET  /api/log-level      loggnivå, felles og per pakke
+//	POST /api/log-level      {"level": "debug", "package": "requests"}, uten package for alle pakker
+//	GET  /api/events         Server-Sent Events med /api/state hver gang tilstanden endres
+//	GET  /metrics            metrikker i tekstformatet til Prometheus
+//	GET  /                   dashboard for hele flåten, se dashboard.html
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"project/elevator_control"
+	"project/elevio"
+	"project/logging"
+	"project/metrics"
+	"project/requests"
+	"time"
+)
+
+var log = logging.New("api")
+
+type Server struct {
+	numFloors int
+	status    *requests.NodeStatus
+	buttons   chan<- elevio.ButtonEvent
+	mux       *http.ServeMux
+}
+
+// buttons skal være ExternalButtons i RequestConfig, status skal være Status
+func NewServer(numFloors int, status *requests.NodeStatus, buttons chan<- elevio.ButtonEvent) *Server {
+	s := &Server{
+		numFloors: numFloors,
+		status:    status,
+		buttons:   buttons,
+		mux:       http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/api/state", s.get(s.handleState))
+	s.mux.HandleFunc("/api/elevator", s.get(s.handleElevator))
+	s.mux.HandleFunc("/api/hall-requests", s.get(s.handleHallRequests))
+	s.mux.HandleFunc("/api/cab-requests", s.get(s.handleCabRequests))
+	s.mux.HandleFunc("/api/elevators", s.get(s.handleElevators))
+	s.mux.HandleFunc("/api/peers", s.get(s.handlePeers))
+	s.mux.HandleFunc("/api/assignment", s.get(s.handleAssignment))
+	s.mux.HandleFunc("/api/hall-call", s.post(s.handleHallCall))
+	s.mux.HandleFunc("/api/cab-call", s.post(s.handleCabCall))
+	s.mux.HandleFunc("/api/events", s.get(s.handleEvents))
+	s.mux.HandleFunc("/api/log-level", s.handleLogLevel)
+	s.mux.Handle("/metrics", s.get(metrics.Handler().ServeHTTP))
+	s.mux.HandleFunc("/", s.get(s.handleDashboard))
+	return s
+}
+
+// Handle lar andre pakker legge til flere endepunkter på samme server
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	log.Info("HTTP API listening", "addr", addr)
+	return http.ListenAndServe(addr, s)
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.currentState())
+}
+
+func (s *Server) currentState() stateView {
+	state := s.status.Get()
+	return stateView{
+		LocalID:          state.LocalID,
+		Elevator:         elevatorToView(elevator_control.GetElevator(), elevator_control.GetInfoElev()),
+		HallRequests:     hallRequestsToView(state.HallRequests),
+		CabRequests:      cabRequestsToView(state.AllCabRequests),
+		Elevators:        infoElevsToView(state.UpdatedInfoElevs),
+		Peers:            peersView{Peers: state.PeerList, NetworkConnected: state.NetworkConnected},
+		Assignment:       assignmentToView(state.LastAssignment, state.LastAssignmentTime),
+		NetworkConnected: state.NetworkConnected,
+	}
+}
+
+func (s *Server) handleElevator(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, elevatorToView(elevator_control.GetElevator(), elevator_control.GetInfoElev()))
+}
+
+func (s *Server) handleHallRequests(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, hallRequestsToView(s.status.Get().HallRequests))
+}
+
+func (s *Server) handleCabRequests(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, cabRequestsToView(s.status.Get().AllCabRequests))
+}
+
+func (s *Server) handleElevators(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, infoElevsToView(s.status.Get().UpdatedInfoElevs))
+}
+
+func (s *Server) handlePeers(w http.ResponseWriter, r *http.Request) {
+	state := s.status.Get()
+	writeJSON(w, http.StatusOK, peersView{Peers: state.PeerList, NetworkConnected: state.NetworkConnected})
+}
+
+func (s *Server) handleAssignment(w http.ResponseWriter, r *http.Request) {
+	state := s.status.Get()
+	writeJSON(w, http.StatusOK, assignmentToView(state.LastAssignment, state.LastAssignmentTime))
+}
+
+type hallCallRequest struct {
+	Floor     int    `json:"floor"`
+	Direction string `json:"direction"`
+}
+
+type cabCallRequest struct {
+	Floor int `json:"floor"`
+}
+
+func (s *Server) handleHallCall(w http.ResponseWriter, r *http.Request) {
+	call := hallCallRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	button := elevio.BT_HallUp
+	switch call.Direction {
+	case "up":
+	case "down":
+		button = elevio.BT_HallDown
+	default:
+		writeError(w, http.StatusBadRequest, `direction must be "up" or "down"`)
+		return
+	}
+	s.placeCall(w, elevio.ButtonEvent{Floor: call.Floor, Button: button})
+}
+
+func (s *Server) handleCabCall(w http.ResponseWriter, r *http.Request) {
+	call := cabCallRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	s.placeCall(w, elevio.ButtonEvent{Floor: call.Floor, Button: elevio.BT_Cab})
+}
+
+// sender bestillingen til RequestControlLoop som et vanlig knappetrykk
+func (s *Server) placeCall(w http.ResponseWriter, btn elevio.ButtonEvent) {
+	if btn.Floor < 0 || btn.Floor >= s.numFloors {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("floor must be between 0 and %d", s.numFloors-1))
+		return
+	}
+	if (btn.Floor == s.numFloors-1 && btn.Button == elevio.BT_HallUp) || (btn.Floor == 0 && btn.Button == elevio.BT_HallDown) {
+		writeError(w, http.StatusBadRequest, "no hall button in that direction at this floor")
+		return
+	}
+	select {
+	case s.buttons <- btn:
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{"floor": btn.Floor, "button": buttonToS(btn.Button)})
+	case <-time.After(time.Second):
+		writeError(w, http.StatusServiceUnavailable, "request loop is busy, try again")
+	}
+}
+
+func (s *Server) get(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeError(w, http.StatusMethodNotAllowed, "use GET")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Server) post(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, "use POST")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,189 @@
+package api
+
+// JSON-representasjon av tilstanden, med tekst i stedet for tallkoder for state, retning og behaviour
+
+import (
+	"project/datatypes"
+	"project/elevio"
+	"sort"
+	"time"
+)
+
+type stateView struct {
+	LocalID          string                   `json:"localID"`
+	NetworkConnected bool                     `json:"networkConnected"`
+	Elevator         elevatorView             `json:"elevator"`
+	HallRequests     []hallRequestsAtFloor    `json:"hallRequests"`
+	CabRequests      map[string][]requestView `json:"cabRequests"`
+	Elevators        map[string]infoView      `json:"elevators"`
+	Peers            peersView                `json:"peers"`
+	Assignment       assignmentView           `json:"assignment"`
+}
+
+type elevatorView struct {
+	Floor      int      `json:"floor"`
+	Direction  string   `json:"direction"`
+	Behaviour  string   `json:"behaviour"`
+	Available  bool     `json:"available"`
+	MotorFault bool     `json:"motorFault"`
+	Obstructed bool     `json:"obstructed"`
+	StopActive bool     `json:"stopActive"`
+	Orders     []orders `json:"orders"`
+}
+
+type orders struct {
+	HallUp   bool `json:"hallUp"`
+	HallDown bool `json:"hallDown"`
+	Cab      bool `json:"cab"`
+}
+
+type requestView struct {
+	State     string   `json:"state"`
+	Count     int      `json:"count"`
+	AwareList []string `json:"awareList"`
+}
+
+type hallRequestsAtFloor struct {
+	Floor int         `json:"floor"`
+	Up    requestView `json:"up"`
+	Down  requestView `json:"down"`
+}
+
+type infoView struct {
+	Floor      int    `json:"floor"`
+	Direction  string `json:"direction"`
+	Behaviour  string `json:"behaviour"`
+	Available  bool   `json:"available"`
+	MotorFault bool   `json:"motorFault"`
+	Obstructed bool   `json:"obstructed"`
+}
+
+type peersView struct {
+	Peers            []string `json:"peers"`
+	NetworkConnected bool     `json:"networkConnected"`
+}
+
+type assignmentView struct {
+	Orders []orders   `json:"orders"`
+	Time   *time.Time `json:"time,omitempty"`
+}
+
+func elevatorToView(elevator datatypes.Elevator, info datatypes.ElevatorInfo) elevatorView {
+	return elevatorView{
+		Floor:      elevator.CurrentFloor,
+		Direction:  dirToS(elevator.Direction),
+		Behaviour:  behToS(elevator.State),
+		Available:  info.Available,
+		MotorFault: info.MotorFault,
+		Obstructed: info.Obstructed,
+		StopActive: elevator.StopActive,
+		Orders:     ordersToView(elevator.Orders),
+	}
+}
+
+func ordersToView(orderMatrix [][datatypes.N_BUTTONS]bool) []orders {
+	view := make([]orders, len(orderMatrix))
+	for f := range orderMatrix {
+		view[f] = orders{
+			HallUp:   orderMatrix[f][datatypes.BT_HallUP],
+			HallDown: orderMatrix[f][datatypes.BT_HallDOWN],
+			Cab:      orderMatrix[f][datatypes.BT_CAB],
+		}
+	}
+	return view
+}
+
+func requestToView(request datatypes.RequestType) requestView {
+	awareList := append([]string{}, request.AwareList...)
+	sort.Strings(awareList)
+	return requestView{State: stateToS(request.State), Count: request.Count, AwareList: awareList}
+}
+
+func hallRequestsToView(hallRequests [][datatypes.N_HALL_BUTTONS]datatypes.RequestType) []hallRequestsAtFloor {
+	view := make([]hallRequestsAtFloor, len(hallRequests))
+	for f := range hallRequests {
+		view[f] = hallRequestsAtFloor{
+			Floor: f,
+			Up:    requestToView(hallRequests[f][datatypes.BT_HallUP]),
+			Down:  requestToView(hallRequests[f][datatypes.BT_HallDOWN]),
+		}
+	}
+	return view
+}
+
+func cabRequestsToView(allCabRequests map[string][]datatypes.RequestType) map[string][]requestView {
+	view := make(map[string][]requestView, len(allCabRequests))
+	for ID, cabRequests := range allCabRequests {
+		requests := make([]requestView, len(cabRequests))
+		for f := range cabRequests {
+			requests[f] = requestToView(cabRequests[f])
+		}
+		view[ID] = requests
+	}
+	return view
+}
+
+func infoElevsToView(infoElevs map[string]datatypes.ElevatorInfo) map[string]infoView {
+	view := make(map[string]infoView, len(infoElevs))
+	for ID, info := range infoElevs {
+		view[ID] = infoView{
+			Floor:      info.CurrentFloor,
+			Direction:  dirToS(info.Direction),
+			Behaviour:  behToS(info.Behaviour),
+			Available:  info.Available,
+			MotorFault: info.MotorFault,
+			Obstructed: info.Obstructed,
+		}
+	}
+	return view
+}
+
+func assignmentToView(orderMatrix [][datatypes.N_BUTTONS]bool, assignedAt time.Time) assignmentView {
+	view := assignmentView{Orders: ordersToView(orderMatrix)}
+	if !assignedAt.IsZero() {
+		view.Time = &assignedAt
+	}
+	return view
+}
+
+func stateToS(state datatypes.RequestState) string {
+	switch state {
+	case datatypes.Completed:
+		return "completed"
+	case datatypes.Unassigned:
+		return "unassigned"
+	case datatypes.Assigned:
+		return "assigned"
+	}
+	return "unknown"
+}
+
+func dirToS(dir datatypes.Direction) string {
+	switch dir {
+	case datatypes.DIR_DOWN:
+		return "down"
+	case datatypes.DIR_UP:
+		return "up"
+	}
+	return "stop"
+}
+
+func behToS(beh datatypes.ElevBehaviour) string {
+	switch beh {
+	case datatypes.DoorOpen:
+		return "doorOpen"
+	case datatypes.Moving:
+		return "moving"
+	}
+	return "idle"
+}
+
+func buttonToS(button elevio.ButtonType) string {
+	switch button {
+	case elevio.BT_HallUp:
+		return "hallUp"
+	case elevio.BT_HallDown:
+		return "hallDown"
+	}
+	return "cab"
+}
@@ -0,0 +1,66 @@
+package clock
+
+// Klokke som kan byttes ut, slik at timere i fsm og requests kan kjøres på virtuell tid ved replay.
+// Real bruker time-pakken direkte, Virtual går bare fremover når den blir bedt om det.
+
+import "time"
+
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	After(d time.Duration) <-chan time.Time
+}
+
+// Timer oppfører seg som time.Timer, men kanalen hentes med C()
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real returnerer klokken som følger vanlig tid
+func Real() Clock {
+	return realClock{}
+}
+
+// OrReal gir Real dersom clk er nil, brukes for valgfrie klokker i config
+func OrReal(clk Clock) Clock {
+	if clk == nil {
+		return Real()
+	}
+	return clk
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time        { return t.timer.C }
+func (t realTimer) Stop() bool                 { return t.timer.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.timer.Reset(d) }
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.ticker.C }
+func (t realTicker) Stop()               { t.ticker.Stop() }
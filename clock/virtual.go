@@ -0,0 +1,199 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Virtual er en klokke som bare går fremover med Advance og AdvanceTo. Timere og tickere som
+// går ut sender på kanalen sin uten å blokkere, slik som i time-pakken, og i rekkefølge etter tidspunkt.
+type Virtual struct {
+	mtx     sync.Mutex
+	now     time.Time
+	waiters map[*virtualWaiter]bool
+	nextSeq uint64
+	changed *sync.Cond // signaliseres når en timer eller ticker startes
+}
+
+type virtualWaiter struct {
+	deadline time.Time
+	period   time.Duration // 0 for timere
+	c        chan time.Time
+	seq      uint64 // rekkefølge ved likt tidspunkt, slik at utløsningene blir deterministiske
+}
+
+func NewVirtual(start time.Time) *Virtual {
+	c := &Virtual{now: start, waiters: make(map[*virtualWaiter]bool)}
+	c.changed = sync.NewCond(&c.mtx)
+	return c
+}
+
+// NewFake gir en virtuell klokke som starter på et fast tidspunkt, slik at tester blir like hver gang
+func NewFake() *Virtual {
+	return NewVirtual(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func (c *Virtual) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+func (c *Virtual) NewTimer(d time.Duration) Timer {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	w := &virtualWaiter{deadline: c.now.Add(d), c: make(chan time.Time, 1), seq: c.newSeq()}
+	c.waiters[w] = true
+	c.changed.Broadcast()
+	return &virtualTimer{clock: c, waiter: w}
+}
+
+func (c *Virtual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	w := &virtualWaiter{deadline: c.now.Add(d), period: d, c: make(chan time.Time, 1), seq: c.newSeq()}
+	c.waiters[w] = true
+	c.changed.Broadcast()
+	return &virtualTicker{clock: c, waiter: w}
+}
+
+func (c *Virtual) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NextDeadline gir tidspunktet til den neste timeren eller tickeren som skal gå ut
+func (c *Virtual) NextDeadline() (time.Time, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	next := c.earliest()
+	if next == nil {
+		return time.Time{}, false
+	}
+	return next.deadline, true
+}
+
+// Waiters gir antall timere og tickere som er aktive
+func (c *Virtual) Waiters() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil venter til minst n timere og tickere er aktive, f.eks. til en goroutine har startet timeren sin
+// før klokken flyttes. Returnerer false dersom det ikke skjer innen timeout i vanlig tid
+func (c *Virtual) BlockUntil(n int, timeout time.Duration) bool {
+	expired := false
+	timer := time.AfterFunc(timeout, func() {
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		expired = true
+		c.changed.Broadcast()
+	})
+	defer timer.Stop()
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for len(c.waiters) < n && !expired {
+		c.changed.Wait()
+	}
+	return len(c.waiters) >= n
+}
+
+// AdvanceStepwise går fram til t én utløsning av gangen og kaller settle etter hver, slik at mottakerne
+// rekker å reagere og starte nye timere før klokken går videre
+func (c *Virtual) AdvanceStepwise(t time.Time, settle func()) {
+	for {
+		next, ok := c.NextDeadline()
+		if !ok || next.After(t) {
+			break
+		}
+		c.AdvanceTo(next)
+		settle()
+	}
+	c.AdvanceTo(t)
+}
+
+// Advance flytter klokken d fremover, se AdvanceTo
+func (c *Virtual) Advance(d time.Duration) {
+	c.AdvanceTo(c.Now().Add(d))
+}
+
+// AdvanceTo flytter klokken til t og lar alle timere og tickere med tidspunkt før eller lik t gå ut.
+// Timere som startes på nytt av den som mottar, blir bare med dersom det skjer før AdvanceTo er ferdig.
+// Den som trenger at mottakerne rekker å reagere mellom hver utløsning, bruker NextDeadline og går ett steg av gangen.
+func (c *Virtual) AdvanceTo(t time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for {
+		next := c.earliest()
+		if next == nil || next.deadline.After(t) {
+			break
+		}
+		c.now = next.deadline
+		select {
+		case next.c <- c.now:
+		default: // som i time-pakken: mottakeren har ikke lest forrige verdi
+		}
+		if next.period > 0 {
+			next.deadline = next.deadline.Add(next.period)
+		} else {
+			delete(c.waiters, next)
+		}
+	}
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+func (c *Virtual) earliest() *virtualWaiter {
+	var next *virtualWaiter
+	for w := range c.waiters {
+		if next == nil || w.deadline.Before(next.deadline) || (w.deadline.Equal(next.deadline) && w.seq < next.seq) {
+			next = w
+		}
+	}
+	return next
+}
+
+func (c *Virtual) newSeq() uint64 {
+	c.nextSeq++
+	return c.nextSeq
+}
+
+// stopper w, returnerer om den var aktiv
+func (c *Virtual) remove(w *virtualWaiter) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	active := c.waiters[w]
+	delete(c.waiters, w)
+	return active
+}
+
+type virtualTimer struct {
+	clock  *Virtual
+	waiter *virtualWaiter
+}
+
+func (t *virtualTimer) C() <-chan time.Time { return t.waiter.c }
+func (t *virtualTimer) Stop() bool          { return t.clock.remove(t.waiter) }
+
+func (t *virtualTimer) Reset(d time.Duration) bool {
+	t.clock.mtx.Lock()
+	defer t.clock.mtx.Unlock()
+	active := t.clock.waiters[t.waiter]
+	t.waiter.deadline = t.clock.now.Add(d)
+	t.clock.waiters[t.waiter] = true
+	t.clock.changed.Broadcast()
+	return active
+}
+
+type virtualTicker struct {
+	clock  *Virtual
+	waiter *virtualWaiter
+}
+
+func (t *virtualTicker) C() <-chan time.Time { return t.waiter.c }
+func (t *virtualTicker) Stop()               { t.clock.remove(t.waiter) }
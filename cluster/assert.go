@@ -0,0 +1,149 @@
+package cluster
+
+// sjekker for scenarioer: at alle knappetrykk blir tatt og at nodene ser hverandre
+
+import (
+	"fmt"
+	"project/elevio"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WaitUntil venter til cond er sann, eller til timeout. Returnerer om cond ble sann
+func (c *Cluster) WaitUntil(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if cond() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(WAIT_POLL_INTERVAL)
+	}
+}
+
+// Unserved gir knappetrykkene som ikke er tatt ennå. En hall request er tatt når en node som kjører
+// har talt den opp etter trykket, en cab request når noden den ble trykket på har gjort det
+func (c *Cluster) Unserved() []Call {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	unserved := []Call{}
+	for _, call := range c.calls {
+		if c.countLocked(call) <= call.count {
+			unserved = append(unserved, call)
+		}
+	}
+	return unserved
+}
+
+// WaitAllServed venter til alle knappetrykk så langt er tatt, og glemmer dem da
+func (c *Cluster) WaitAllServed(timeout time.Duration) error {
+	served := c.WaitUntil(timeout, func() bool { return len(c.Unserved()) == 0 })
+	if !served {
+		lines := []string{}
+		for _, call := range c.Unserved() {
+			lines = append(lines, call.String())
+		}
+		return fmt.Errorf("%d calls not served within %s: %s", len(lines), timeout, strings.Join(lines, "; "))
+	}
+	c.mtx.Lock()
+	c.calls = nil
+	c.mtx.Unlock()
+	return nil
+}
+
+// WaitConnected venter til hver node som kjører har nøyaktig de nodene den når i nettverket som peers
+func (c *Cluster) WaitConnected(timeout time.Duration) error {
+	connected := c.WaitUntil(timeout, func() bool { return len(c.peerMismatches()) == 0 })
+	if !connected {
+		return fmt.Errorf("peers not settled within %s: %s", timeout, strings.Join(c.peerMismatches(), "; "))
+	}
+	return nil
+}
+
+func (c *Cluster) peerMismatches() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.network.mtx.Lock()
+	defer c.network.mtx.Unlock()
+
+	mismatches := []string{}
+	for _, from := range c.ids {
+		if !c.nodes[from].alive {
+			continue
+		}
+		expected := []string{}
+		for _, to := range c.ids {
+			if c.nodes[to].alive && c.network.canReach(from, to) {
+				expected = append(expected, to)
+			}
+		}
+		peerList := append([]string{}, c.nodes[from].Status.Get().PeerList...)
+		sort.Strings(peerList)
+		if strings.Join(peerList, ",") != strings.Join(expected, ",") {
+			mismatches = append(mismatches, fmt.Sprintf("%s sees [%s], expected [%s]", from,
+				strings.Join(peerList, ","), strings.Join(expected, ",")))
+		}
+	}
+	return mismatches
+}
+
+// HallLampsConsistent sjekker at alle nodene som kjører og når hverandre har de samme hall-lampene tent
+func (c *Cluster) HallLampsConsistent() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.network.mtx.Lock()
+	defer c.network.mtx.Unlock()
+
+	problems := []string{}
+	for i, a := range c.ids {
+		for _, b := range c.ids[i+1:] {
+			if !c.nodes[a].alive || !c.nodes[b].alive || !c.network.canReach(a, b) {
+				continue
+			}
+			for f := 0; f < c.config.NumFloors; f++ {
+				for _, button := range []elevio.ButtonType{elevio.BT_HallUp, elevio.BT_HallDown} {
+					lampA := c.nodes[a].Sim.ButtonLamp(button, f)
+					lampB := c.nodes[b].Sim.ButtonLamp(button, f)
+					if lampA != lampB {
+						problems = append(problems, fmt.Sprintf("%s floor %d: %s=%t %s=%t",
+							buttonToS(button), f, a, lampA, b, lampB))
+					}
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// HallRequestsConsistent sjekker at alle nodene som kjører og når hverandre er enige om state og Count
+// for hver hall request
+func (c *Cluster) HallRequestsConsistent() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.network.mtx.Lock()
+	defer c.network.mtx.Unlock()
+
+	problems := []string{}
+	for i, a := range c.ids {
+		for _, b := range c.ids[i+1:] {
+			if !c.nodes[a].alive || !c.nodes[b].alive || !c.network.canReach(a, b) {
+				continue
+			}
+			hallA := c.nodes[a].Status.Get().HallRequests
+			hallB := c.nodes[b].Status.Get().HallRequests
+			for f := 0; f < len(hallA) && f < len(hallB); f++ {
+				for _, button := range []elevio.ButtonType{elevio.BT_HallUp, elevio.BT_HallDown} {
+					requestA, requestB := hallA[f][button], hallB[f][button]
+					if requestA.State != requestB.State || requestA.Count != requestB.Count {
+						problems = append(problems, fmt.Sprintf("%s floor %d: %s=%s/%d %s=%s/%d", buttonToS(button), f,
+							a, requestA.State, requestA.Count, b, requestB.State, requestB.Count))
+					}
+				}
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,295 @@
+package cluster
+
+// Cluster starter flere fullstendige noder i samme prosess: fsm og RequestControlLoop for hver node,
+// hver med sin egen simulerte heis fra elevsim, koblet sammen med et Network i minnet. Brukes til å
+// kjøre scenarioer med partisjoner, pakketap og noder som krasjer, og sjekke at alle bestillinger
+// til slutt blir tatt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"project/datatypes"
+	"project/elevator_control"
+	"project/elevio"
+	"project/elevsim"
+	"project/fsm"
+	"project/requests"
+	request_handler "project/requests/request_handler"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_NODES      = 3
+	DEFAULT_NUM_FLOORS = 4
+	BUTTON_PRESS_TIME  = 100 * time.Millisecond
+	WAIT_POLL_INTERVAL = 50 * time.Millisecond
+)
+
+type Config struct {
+	Nodes      int
+	NumFloors  int
+	TravelTime time.Duration // tid mellom to etasjer i simulatoren, 0 gir elevsim.DEFAULT_TRAVEL_TIME
+	Assigner   string        // tom streng gir request_handler.ASSIGNER_TIME_TO_IDLE
+	Dir        string        // katalog for cab-journalene, tom streng gir en midlertidig katalog
+	Seed       int64         // for pakketap og jitter i nettverket
+
+	ObstructionTimeout int  // sekunder, 0 gir fsm.OBSTRUCTION_TIMEOUT
+	OfflineHallCalls   bool // se requests.RequestConfig
+}
+
+type Node struct {
+	ID     string
+	Sim    *elevsim.Simulator
+	Shared *elevator_control.Shared
+	Status *requests.NodeStatus
+
+	cabJournalPath string
+	driver         *elevsim.Driver
+	done           chan struct{}
+	alive          bool
+}
+
+type Cluster struct {
+	mtx      sync.Mutex
+	config   Config
+	assigner request_handler.Assigner
+	network  *Network
+	nodes    map[string]*Node
+	ids      []string
+	calls    []Call
+	tmpDir   string
+}
+
+// Call er et knappetrykk harnesset venter på at blir tatt
+type Call struct {
+	Node    string
+	Floor   int
+	Button  elevio.ButtonType
+	Pressed time.Time
+	count   int // Count for bestillingen da knappen ble trykket, bestillingen er tatt når Count er økt
+}
+
+func (c Call) String() string {
+	return fmt.Sprintf("%s floor %d on %s (pressed %s ago)", buttonToS(c.Button), c.Floor, c.Node,
+		time.Since(c.Pressed).Round(time.Millisecond))
+}
+
+// New lager og starter alle nodene. Nodene heter node1, node2 osv.
+func New(config Config) (*Cluster, error) {
+	if config.Nodes <= 0 {
+		config.Nodes = DEFAULT_NODES
+	}
+	if config.NumFloors <= 0 {
+		config.NumFloors = DEFAULT_NUM_FLOORS
+	}
+	if config.Assigner == "" {
+		config.Assigner = request_handler.ASSIGNER_TIME_TO_IDLE
+	}
+	assigner, err := request_handler.NewAssigner(config.Assigner)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cluster{
+		config:   config,
+		assigner: assigner,
+		network:  NewNetwork(config.Seed),
+		nodes:    make(map[string]*Node),
+	}
+	if config.Dir == "" {
+		c.tmpDir, err = os.MkdirTemp("", "cluster")
+		if err != nil {
+			return nil, err
+		}
+		c.config.Dir = c.tmpDir
+	}
+
+	for i := 1; i <= config.Nodes; i++ {
+		ID := fmt.Sprintf("node%d", i)
+		node := &Node{
+			ID:             ID,
+			Sim:            elevsim.New(elevsim.Config{NumFloors: config.NumFloors, TravelTime: config.TravelTime}),
+			cabJournalPath: filepath.Join(c.config.Dir, "cab_requests_"+ID+".journal"),
+		}
+		c.nodes[ID] = node
+		c.ids = append(c.ids, ID)
+		c.start(node)
+	}
+	return c, nil
+}
+
+// starter fsm og RequestControlLoop for noden, med ny tilstand som etter en omstart av prosessen
+func (c *Cluster) start(node *Node) {
+	node.done = make(chan struct{})
+	node.driver = node.Sim.NewDriver()
+	node.Shared = elevator_control.NewShared()
+	node.Status = requests.NewNodeStatus()
+	node.alive = true
+
+	reqChan := make(chan [][datatypes.N_BUTTONS]bool)
+	completedReqChan := make(chan datatypes.ButtonEvent)
+
+	fsmConfig := fsm.FSMConfig{
+		NumFloors:          c.config.NumFloors,
+		ObstructionTimeout: c.config.ObstructionTimeout,
+		Shared:             node.Shared,
+		Done:               node.done,
+	}
+	requestConfig := requests.RequestConfig{
+		LocalID:          node.ID,
+		NumFloors:        c.config.NumFloors,
+		Assigner:         c.assigner,
+		CabJournalPath:   node.cabJournalPath,
+		OfflineHallCalls: c.config.OfflineHallCalls,
+		Status:           node.Status,
+		Transport:        c.network.Transport(node.ID),
+		Shared:           node.Shared,
+		Done:             node.done,
+	}
+	go fsm.RunElevFSM(node.driver, fsmConfig, reqChan, completedReqChan)
+	go requests.RequestControlLoop(node.driver, requestConfig, reqChan, completedReqChan)
+}
+
+func (c *Cluster) Network() *Network {
+	return c.network
+}
+
+func (c *Cluster) IDs() []string {
+	return append([]string{}, c.ids...)
+}
+
+// Node gir noden med ID id, eller nil
+func (c *Cluster) Node(id string) *Node {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.nodes[id]
+}
+
+func (c *Cluster) Alive(id string) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	node, exists := c.nodes[id]
+	return exists && node.alive
+}
+
+// Kill stopper noden som om prosessen krasjet. Heisen står igjen slik den var, med motoren i gang
+// dersom den kjørte
+func (c *Cluster) Kill(id string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	node, exists := c.nodes[id]
+	if !exists {
+		return fmt.Errorf("unknown node %q", id)
+	}
+	if !node.alive {
+		return fmt.Errorf("node %s is already down", id)
+	}
+	node.alive = false
+	close(node.done)
+	node.driver.Close()
+	c.network.disconnect(id)
+	return nil
+}
+
+// Restart starter en drept node på nytt med samme heis og cab-journal
+func (c *Cluster) Restart(id string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	node, exists := c.nodes[id]
+	if !exists {
+		return fmt.Errorf("unknown node %q", id)
+	}
+	if node.alive {
+		return fmt.Errorf("node %s is already running", id)
+	}
+	c.start(node)
+	return nil
+}
+
+// Stop dreper alle nodene og sletter den midlertidige katalogen
+func (c *Cluster) Stop() {
+	for _, ID := range c.ids {
+		if c.Alive(ID) {
+			c.Kill(ID)
+		}
+	}
+	if c.tmpDir != "" {
+		os.RemoveAll(c.tmpDir)
+	}
+}
+
+// PressHall trykker på en hall-knapp i heisen til noden id og husker trykket, slik at
+// WaitAllServed kan vente på at det blir tatt
+func (c *Cluster) PressHall(id string, floor int, button elevio.ButtonType) error {
+	if button != elevio.BT_HallUp && button != elevio.BT_HallDown {
+		return fmt.Errorf("not a hall button: %d", button)
+	}
+	return c.press(id, floor, button)
+}
+
+func (c *Cluster) PressCab(id string, floor int) error {
+	return c.press(id, floor, elevio.BT_Cab)
+}
+
+func (c *Cluster) press(id string, floor int, button elevio.ButtonType) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	node, exists := c.nodes[id]
+	if !exists {
+		return fmt.Errorf("unknown node %q", id)
+	}
+	if !node.alive {
+		return fmt.Errorf("node %s is down", id)
+	}
+	if floor < 0 || floor >= c.config.NumFloors {
+		return fmt.Errorf("floor %d out of range", floor)
+	}
+	call := Call{Node: id, Floor: floor, Button: button, Pressed: time.Now()}
+	call.count = c.countLocked(call)
+	c.calls = append(c.calls, call)
+	node.Sim.Press(button, floor, BUTTON_PRESS_TIME)
+	return nil
+}
+
+// høyeste Count for bestillingen blant nodene som kjører. Cab requests telles bare hos noden selv
+func (c *Cluster) countLocked(call Call) int {
+	count := 0
+	for _, node := range c.nodes {
+		if !node.alive {
+			continue
+		}
+		state := node.Status.Get()
+		if call.Button == elevio.BT_Cab {
+			if node.ID != call.Node {
+				continue
+			}
+			if cabRequests := state.AllCabRequests[call.Node]; call.Floor < len(cabRequests) {
+				count = max(count, cabRequests[call.Floor].Count)
+			}
+		} else if call.Floor < len(state.HallRequests) {
+			count = max(count, state.HallRequests[call.Floor][call.Button].Count)
+		}
+	}
+	return count
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func buttonToS(button elevio.ButtonType) string {
+	switch button {
+	case elevio.BT_HallUp:
+		return "hall up"
+	case elevio.BT_HallDown:
+		return "hall down"
+	case elevio.BT_Cab:
+		return "cab"
+	}
+	return "unknown"
+}
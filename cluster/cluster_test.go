@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"project/logging"
+	"testing"
+	"time"
+)
+
+const (
+	TEST_TRAVEL_TIME = 200 * time.Millisecond
+	TEST_TIMEOUT     = 60 * time.Second
+	TEST_SEED        = 1
+)
+
+// starter et cluster med samme innstillinger som cmd/clustertest, men kortere reisetid
+func newTestCluster(t *testing.T) *Cluster {
+	if err := logging.ApplyLevelSpec("warn"); err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(Config{
+		Nodes:      DEFAULT_NODES,
+		NumFloors:  DEFAULT_NUM_FLOORS,
+		TravelTime: TEST_TRAVEL_TIME,
+		Dir:        t.TempDir(),
+		Seed:       TEST_SEED,
+
+		ObstructionTimeout: 2,
+		OfflineHallCalls:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Stop)
+	return c
+}
+
+func runScenario(t *testing.T, name string, slow bool) {
+	if slow && testing.Short() {
+		t.Skip("slow cluster scenario, skipped with -short")
+	}
+	scenario, found := FindScenario(name)
+	if !found {
+		t.Fatal("unknown scenario", name)
+	}
+	if err := scenario.Run(newTestCluster(t), TEST_TIMEOUT); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterBasic(t *testing.T) {
+	runScenario(t, "basic", false)
+}
+
+func TestClusterPartition(t *testing.T) {
+	runScenario(t, "partition", true)
+}
+
+func TestClusterLossy(t *testing.T) {
+	runScenario(t, "lossy", true)
+}
+
+func TestClusterRestart(t *testing.T) {
+	runScenario(t, "restart", true)
+}
+
+func TestClusterRejoin(t *testing.T) {
+	runScenario(t, "rejoin", false)
+}
+
+func TestClusterOffline(t *testing.T) {
+	runScenario(t, "offline", true)
+}
+
+func TestClusterObstruction(t *testing.T) {
+	runScenario(t, "obstruction", true)
+}
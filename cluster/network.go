@@ -0,0 +1,281 @@
+package cluster
+
+// nettverk i minnet mellom nodene i et Cluster. Heartbeats og NetworkMsg går samme vei, slik at
+// partisjoner, tap og forsinkelse rammer begge som på et ekte nettverk. En node hører alltid
+// sine egne heartbeats, slik som med UDP broadcast, med mindre den er koblet fra med Unplug
+
+import (
+	"math/rand"
+	"project/clock"
+	"project/datatypes"
+	"project/network/peers"
+	"project/requests"
+	"sync"
+	"time"
+)
+
+const INBOX_SIZE = 64 // meldinger som venter på en node, nye meldinger kastes når køen er full
+
+type Network struct {
+	mtx       sync.Mutex
+	endpoints map[string]*endpoint
+	groups    map[string]int // gruppen hver node er i, tom når nettverket er helt
+	unplugged map[string]bool
+	dropRate  float64
+	delay     time.Duration
+	jitter    time.Duration
+	rng       *rand.Rand
+
+	delivered uint64
+	dropped   uint64
+}
+
+func NewNetwork(seed int64) *Network {
+	return &Network{
+		endpoints: make(map[string]*endpoint),
+		groups:    make(map[string]int),
+		unplugged: make(map[string]bool),
+		rng:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Partition deler nettverket i grupper som ikke når hverandre. Noder som ikke er med i noen
+// gruppe havner i en egen gruppe sammen
+func (n *Network) Partition(groups ...[]string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.groups = make(map[string]int)
+	for i, group := range groups {
+		for _, ID := range group {
+			n.groups[ID] = i + 1
+		}
+	}
+}
+
+// Heal fjerner alle partisjoner
+func (n *Network) Heal() {
+	n.Partition()
+}
+
+// Unplug kobler noden helt fra nettverket, som når kabelen trekkes ut. Den hører da heller ikke
+// sine egne heartbeats, og regner seg selv som frakoblet
+func (n *Network) Unplug(id string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.unplugged[id] = true
+}
+
+// Plug kobler noden til nettverket igjen etter Unplug
+func (n *Network) Plug(id string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	delete(n.unplugged, id)
+}
+
+// SetDropRate setter sannsynligheten for at en pakke mellom to noder forsvinner
+func (n *Network) SetDropRate(p float64) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.dropRate = p
+}
+
+// SetDelay forsinker alle pakker mellom to noder med delay pluss opptil jitter. Med jitter kan
+// pakker komme fram i en annen rekkefølge enn de ble sendt
+func (n *Network) SetDelay(delay time.Duration, jitter time.Duration) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.delay = delay
+	n.jitter = jitter
+}
+
+// Stats gir antall pakker som er levert og kastet
+func (n *Network) Stats() (delivered uint64, dropped uint64) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.delivered, n.dropped
+}
+
+func (n *Network) canReach(from string, to string) bool {
+	if n.unplugged[from] || n.unplugged[to] {
+		return false
+	}
+	return from == to || n.groups[from] == n.groups[to]
+}
+
+// Transport gir en ny transport for noden med ID id. En eldre transport for samme node kobles fra
+func (n *Network) Transport(id string) requests.Transport {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	if old, exists := n.endpoints[id]; exists {
+		old.close()
+	}
+	e := &endpoint{
+		id:         id,
+		network:    n,
+		inbox:      make(chan datatypes.NetworkMsg, INBOX_SIZE),
+		heartbeats: make(chan string, INBOX_SIZE),
+		done:       make(chan struct{}),
+	}
+	n.endpoints[id] = e
+	return e
+}
+
+// kobler fra noden, brukes når den drepes
+func (n *Network) disconnect(id string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	if e, exists := n.endpoints[id]; exists {
+		e.close()
+		delete(n.endpoints, id)
+	}
+}
+
+// sender en heartbeat eller en melding fra from til alle noder den når
+func (n *Network) broadcast(from string, heartbeat bool, msg datatypes.NetworkMsg) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	for to, e := range n.endpoints {
+		if !n.canReach(from, to) {
+			continue
+		}
+		delay := time.Duration(0)
+		if from != to {
+			if n.rng.Float64() < n.dropRate {
+				n.dropped++
+				continue
+			}
+			delay = n.delay
+			if n.jitter > 0 {
+				delay += time.Duration(n.rng.Int63n(int64(n.jitter)))
+			}
+		}
+		n.delivered++
+		e := e // leveringen kan skje etter at løkken har gått videre til neste endpoint
+
+		// hver mottaker får sin egen kopi, slik at nodene ikke deler tabeller
+		if heartbeat {
+			deliver := func() { e.enqueueHeartbeat(from) }
+			n.schedule(delay, deliver)
+		} else {
+			copied := msg
+			copied.Capabilities = append([]string{}, msg.Capabilities...)
+			copied.SenderHallRequests = datatypes.CopyHallRequests(msg.SenderHallRequests)
+			copied.AllCabRequests = datatypes.CopyAllCabRequests(msg.AllCabRequests)
+			deliver := func() { e.enqueue(copied) }
+			n.schedule(delay, deliver)
+		}
+	}
+}
+
+func (n *Network) schedule(delay time.Duration, deliver func()) {
+	if delay <= 0 {
+		deliver()
+		return
+	}
+	time.AfterFunc(delay, deliver)
+}
+
+func (n *Network) countDropped() {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.dropped++
+}
+
+type endpoint struct {
+	id         string
+	network    *Network
+	inbox      chan datatypes.NetworkMsg
+	heartbeats chan string
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+func (e *endpoint) close() {
+	e.closeOnce.Do(func() { close(e.done) })
+}
+
+func (e *endpoint) enqueue(msg datatypes.NetworkMsg) {
+	select {
+	case e.inbox <- msg:
+	default:
+		e.network.countDropped()
+	}
+}
+
+func (e *endpoint) enqueueHeartbeat(from string) {
+	select {
+	case e.heartbeats <- from:
+	default:
+	}
+}
+
+func (e *endpoint) Start(localID string, send <-chan datatypes.NetworkMsg, receive chan<- datatypes.NetworkMsg,
+	peerUpdates chan<- peers.PeerUpdate) {
+
+	clk := clock.Real()
+
+	// sender heartbeats
+	go func() {
+		ticker := clk.NewTicker(peers.HEARTBEAT_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				e.network.broadcast(localID, true, datatypes.NetworkMsg{})
+			case <-e.done:
+				return
+			}
+		}
+	}()
+
+	// holder oversikt over peers på samme måte som peers.Receiver
+	go func() {
+		tracker := peers.NewTracker(clk)
+		ticker := clk.NewTicker(peers.HEARTBEAT_INTERVAL)
+		defer ticker.Stop()
+		for {
+			id := ""
+			select {
+			case id = <-e.heartbeats:
+			case <-ticker.C():
+			case <-e.done:
+				return
+			}
+			if p, updated := tracker.Update(id); updated {
+				select {
+				case peerUpdates <- p:
+				case <-e.done:
+					return
+				}
+			}
+		}
+	}()
+
+	// sender meldinger
+	go func() {
+		for {
+			select {
+			case msg := <-send:
+				e.network.broadcast(localID, false, msg)
+			case <-e.done:
+				return
+			}
+		}
+	}()
+
+	// leverer mottatte meldinger
+	go func() {
+		for {
+			select {
+			case msg := <-e.inbox:
+				select {
+				case receive <- msg:
+				case <-e.done:
+					return
+				}
+			case <-e.done:
+				return
+			}
+		}
+	}()
+}
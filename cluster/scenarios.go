@@ -0,0 +1,351 @@
+package cluster
+
+// scenarioer som kjøres av cmd/clustertest og TestCluster-testene. Hvert scenario får et nystartet Cluster og returnerer
+// en feil dersom en av sjekkene feiler
+
+import (
+	"fmt"
+	"os"
+	"project/elevio"
+	"time"
+)
+
+type Scenario struct {
+	Name        string
+	Description string
+	Run         func(c *Cluster, timeout time.Duration) error
+}
+
+var Scenarios = []Scenario{
+	{"basic", "hall and cab calls on every node are served", scenarioBasic},
+	{"partition", "calls made on both sides of a partition are served after it heals", scenarioPartition},
+	{"lossy", "calls are served with packet loss, delay and reordering", scenarioLossy},
+	{"restart", "a killed node's hall calls are taken over and its cab calls are served after restart", scenarioRestart},
+	{"rejoin", "a node restarted without its cab journal gets its cab calls back from peers before it starts assigning", scenarioRejoin},
+	{"offline", "a node cut off from the network serves hall calls alone and agrees with the others after reconnecting", scenarioOffline},
+	{"obstruction", "a node with its door obstructed too long is taken out of service and its hall calls are served by the others", scenarioObstruction},
+}
+
+func FindScenario(name string) (Scenario, bool) {
+	for _, scenario := range Scenarios {
+		if scenario.Name == name {
+			return scenario, true
+		}
+	}
+	return Scenario{}, false
+}
+
+// trykker på hall-knapper spredt over etasjene og nodene
+func pressSpread(c *Cluster, ids []string) error {
+	top := c.config.NumFloors - 1
+	for i, ID := range ids {
+		if err := c.PressHall(ID, top-i%c.config.NumFloors, elevio.BT_HallDown); err != nil {
+			return err
+		}
+		if err := c.PressHall(ID, i%top, elevio.BT_HallUp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lampene oppdateres først når nodene har hørt fra hverandre, så det ventes litt på at de blir like
+func checkLamps(c *Cluster, timeout time.Duration) error {
+	consistent := c.WaitUntil(timeout, func() bool { return len(c.HallLampsConsistent()) == 0 })
+	if !consistent {
+		return fmt.Errorf("hall lamps differ: %v", c.HallLampsConsistent())
+	}
+	return nil
+}
+
+func scenarioBasic(c *Cluster, timeout time.Duration) error {
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+	if err := pressSpread(c, c.IDs()); err != nil {
+		return err
+	}
+	for i, ID := range c.IDs() {
+		if err := c.PressCab(ID, (i+1)%c.config.NumFloors); err != nil {
+			return err
+		}
+	}
+	if err := c.WaitAllServed(timeout); err != nil {
+		return err
+	}
+	return checkLamps(c, timeout)
+}
+
+func scenarioPartition(c *Cluster, timeout time.Duration) error {
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+	ids := c.IDs()
+	c.Network().Partition(ids[:1], ids[1:])
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+	if err := pressSpread(c, ids); err != nil {
+		return err
+	}
+	time.Sleep(time.Second)
+	c.Network().Heal()
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+	if err := c.WaitAllServed(timeout); err != nil {
+		return err
+	}
+	return checkLamps(c, timeout)
+}
+
+func scenarioLossy(c *Cluster, timeout time.Duration) error {
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+	c.Network().SetDropRate(0.3)
+	c.Network().SetDelay(20*time.Millisecond, 40*time.Millisecond)
+	if err := pressSpread(c, c.IDs()); err != nil {
+		return err
+	}
+	if err := c.WaitAllServed(timeout); err != nil {
+		return err
+	}
+	c.Network().SetDropRate(0)
+	c.Network().SetDelay(0, 0)
+	return nil
+}
+
+func scenarioRestart(c *Cluster, timeout time.Duration) error {
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+	ids := c.IDs()
+	victim := ids[len(ids)-1]
+	top := c.config.NumFloors - 1
+	if err := c.PressCab(victim, top); err != nil {
+		return err
+	}
+	if err := c.PressHall(victim, top, elevio.BT_HallDown); err != nil {
+		return err
+	}
+	// venter til de andre har fått med seg bestillingene før noden dreper
+	time.Sleep(500 * time.Millisecond)
+	if err := c.Kill(victim); err != nil {
+		return err
+	}
+	if err := c.PressHall(ids[0], 0, elevio.BT_HallUp); err != nil {
+		return err
+	}
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+
+	// hall requests skal tas av de andre mens noden er nede
+	tookOver := c.WaitUntil(timeout, func() bool {
+		for _, call := range c.Unserved() {
+			if call.Button != elevio.BT_Cab {
+				return false
+			}
+		}
+		return true
+	})
+	if !tookOver {
+		return fmt.Errorf("hall calls not taken over while %s was down: %v", victim, c.Unserved())
+	}
+
+	if err := c.Restart(victim); err != nil {
+		return err
+	}
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+	return c.WaitAllServed(timeout)
+}
+
+func scenarioRejoin(c *Cluster, timeout time.Duration) error {
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+	ids := c.IDs()
+	victim := c.Node(ids[len(ids)-1])
+	top := c.config.NumFloors - 1
+	if err := c.PressCab(victim.ID, top); err != nil {
+		return err
+	}
+	// venter til de andre har fått med seg bestillingen før noden drepes
+	time.Sleep(500 * time.Millisecond)
+	if err := c.Kill(victim.ID); err != nil {
+		return err
+	}
+	// uten journalen kan cab requesten bare komme tilbake fra peers
+	if err := os.Remove(victim.cabJournalPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := c.Restart(victim.ID); err != nil {
+		return err
+	}
+
+	restored := c.WaitUntil(timeout, func() bool {
+		state := victim.Status.Get()
+		return state.LocalID != "" && !state.RestoringCabs
+	})
+	if !restored {
+		return fmt.Errorf("%s did not finish restoring cab requests", victim.ID)
+	}
+	if !victim.Sim.ButtonLamp(elevio.BT_Cab, top) {
+		return fmt.Errorf("cab lamp at floor %d on %s is not lit after restore", top, victim.ID)
+	}
+	if assignment := victim.Status.Get().LastAssignment; top >= len(assignment) || !assignment[top][elevio.BT_Cab] {
+		return fmt.Errorf("first assignment on %s after restore is missing the cab call at floor %d", victim.ID, top)
+	}
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+	return c.WaitAllServed(timeout)
+}
+
+func scenarioOffline(c *Cluster, timeout time.Duration) error {
+	if !c.config.OfflineHallCalls {
+		return fmt.Errorf("scenario needs OfflineHallCalls")
+	}
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+	ids := c.IDs()
+	victim := c.Node(ids[0])
+	top := c.config.NumFloors - 1
+
+	c.Network().Unplug(victim.ID)
+	disconnected := c.WaitUntil(timeout, func() bool {
+		state := victim.Status.Get()
+		return state.LocalID != "" && !state.NetworkConnected
+	})
+	if !disconnected {
+		return fmt.Errorf("%s did not notice it was disconnected", victim.ID)
+	}
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+
+	// begge sider tar hall calls, også den samme knappen, mens noden er frakoblet
+	if err := c.PressHall(victim.ID, top, elevio.BT_HallDown); err != nil {
+		return err
+	}
+	if err := c.PressHall(victim.ID, 0, elevio.BT_HallUp); err != nil {
+		return err
+	}
+	if err := c.PressHall(ids[1], 0, elevio.BT_HallUp); err != nil {
+		return err
+	}
+	if err := c.WaitAllServed(timeout); err != nil {
+		return err
+	}
+
+	c.Network().Plug(victim.ID)
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+	consistent := c.WaitUntil(timeout, func() bool { return len(c.HallRequestsConsistent()) == 0 })
+	if !consistent {
+		return fmt.Errorf("hall requests differ after reconnecting: %v", c.HallRequestsConsistent())
+	}
+
+	// vanlig drift etterpå
+	if err := pressSpread(c, ids); err != nil {
+		return err
+	}
+	if err := c.WaitAllServed(timeout); err != nil {
+		return err
+	}
+	return checkLamps(c, timeout)
+}
+
+func scenarioObstruction(c *Cluster, timeout time.Duration) error {
+	if err := c.WaitConnected(timeout); err != nil {
+		return err
+	}
+	ids := c.IDs()
+	victim := c.Node(ids[0])
+
+	// med døren lukket skal obstruction ikke gjøre noe
+	victim.Sim.SetObstruction(true)
+	time.Sleep(500 * time.Millisecond)
+	if !victim.Shared.GetInfoElev().Available {
+		return fmt.Errorf("%s became unavailable from obstruction with the door closed", victim.ID)
+	}
+
+	// døren åpnes mens bryteren er på, og skal holdes åpen til heisen tas ut av drift
+	floor := victim.Shared.GetElevator().CurrentFloor
+	if err := c.PressCab(victim.ID, floor); err != nil {
+		return err
+	}
+	outOfService := c.WaitUntil(timeout, func() bool { return victim.Shared.GetInfoElev().Obstructed })
+	if !outOfService {
+		return fmt.Errorf("%s was not taken out of service while obstructed", victim.ID)
+	}
+	if !victim.Sim.DoorOpenLamp() {
+		return fmt.Errorf("%s closed the door while obstructed", victim.ID)
+	}
+	seen := c.WaitUntil(timeout, func() bool {
+		for _, ID := range ids[1:] {
+			info := c.Node(ID).Status.Get().UpdatedInfoElevs[victim.ID]
+			if !info.Obstructed || info.Available {
+				return false
+			}
+		}
+		return true
+	})
+	if !seen {
+		return fmt.Errorf("peers did not see %s as obstructed", victim.ID)
+	}
+
+	// hall calls, også de trykket hos den blokkerte noden, tas av de andre
+	other := (floor + 1) % c.config.NumFloors
+	if err := c.PressHall(victim.ID, other, hallButtonAt(c, other)); err != nil {
+		return err
+	}
+	if err := c.PressHall(ids[1], floor, hallButtonAt(c, floor)); err != nil {
+		return err
+	}
+	// cab requesten i etasjen tas først når døren lukkes
+	tookOver := c.WaitUntil(timeout, func() bool {
+		for _, call := range c.Unserved() {
+			if call.Button != elevio.BT_Cab {
+				return false
+			}
+		}
+		return true
+	})
+	if !tookOver {
+		return fmt.Errorf("hall calls not taken over while %s was obstructed: %v", victim.ID, c.Unserved())
+	}
+	if !victim.Sim.DoorOpenLamp() {
+		return fmt.Errorf("%s closed the door while obstructed", victim.ID)
+	}
+
+	victim.Sim.SetObstruction(false)
+	backInService := c.WaitUntil(timeout, func() bool {
+		info := victim.Shared.GetInfoElev()
+		return info.Available && !info.Obstructed
+	})
+	if !backInService {
+		return fmt.Errorf("%s did not return to service after the obstruction cleared", victim.ID)
+	}
+	closed := c.WaitUntil(timeout, func() bool { return !victim.Sim.DoorOpenLamp() })
+	if !closed {
+		return fmt.Errorf("%s did not close the door after the obstruction cleared", victim.ID)
+	}
+	if err := c.WaitAllServed(timeout); err != nil {
+		return err
+	}
+	return checkLamps(c, timeout)
+}
+
+// en hall-knapp som finnes i etasjen
+func hallButtonAt(c *Cluster, floor int) elevio.ButtonType {
+	if floor == c.config.NumFloors-1 {
+		return elevio.BT_HallDown
+	}
+	return elevio.BT_HallUp
+}
@@ -0,0 +1,84 @@
+package main
+
+// kjører scenarioene i cluster mot flere noder i én prosess, avslutter med kode 1 dersom et scenario feiler
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"project/cluster"
+	"project/logging"
+	"time"
+)
+
+func main() {
+	scenarioFlag := flag.String("scenario", "all", "Scenario to run, or \"all\"")
+	nodesFlag := flag.Int("nodes", cluster.DEFAULT_NODES, "Number of nodes")
+	floorsFlag := flag.Int("floors", cluster.DEFAULT_NUM_FLOORS, "Number of floors")
+	travelFlag := flag.Duration("travel", time.Second, "Travel time between two floors in the simulated elevators")
+	assignerFlag := flag.String("assigner", "", "Hall request assignment strategy (default time-to-idle)")
+	obstructionFlag := flag.Int("obstructiontimeout", 2, "Seconds a node's door can be obstructed before it is taken out of service")
+	offlineFlag := flag.Bool("offlinehall", true, "Let disconnected nodes serve hall calls alone (the offline scenario needs it)")
+	timeoutFlag := flag.Duration("timeout", 60*time.Second, "How long each check waits before failing")
+	seedFlag := flag.Int64("seed", time.Now().UnixNano(), "Seed for packet loss and jitter")
+	logLevelFlag := flag.String("loglevel", "warn", "Log level for the nodes, e.g. \"info\" or \"warn,requests=debug\"")
+	listFlag := flag.Bool("list", false, "List scenarios and exit")
+	flag.Parse()
+
+	if *listFlag {
+		for _, scenario := range cluster.Scenarios {
+			fmt.Printf("%-12s %s\n", scenario.Name, scenario.Description)
+		}
+		return
+	}
+	if err := logging.ApplyLevelSpec(*logLevelFlag); err != nil {
+		fmt.Println("Error: -loglevel:", err)
+		os.Exit(2)
+	}
+	if *nodesFlag < 2 {
+		fmt.Println("Error: -nodes must be at least 2")
+		os.Exit(2)
+	}
+
+	scenarios := cluster.Scenarios
+	if *scenarioFlag != "all" {
+		scenario, found := cluster.FindScenario(*scenarioFlag)
+		if !found {
+			fmt.Println("Error: unknown scenario", *scenarioFlag)
+			os.Exit(2)
+		}
+		scenarios = []cluster.Scenario{scenario}
+	}
+
+	fmt.Println("seed", *seedFlag)
+	failed := 0
+	for _, scenario := range scenarios {
+		c, err := cluster.New(cluster.Config{
+			Nodes:      *nodesFlag,
+			NumFloors:  *floorsFlag,
+			TravelTime: *travelFlag,
+			Assigner:   *assignerFlag,
+			Seed:       *seedFlag,
+
+			ObstructionTimeout: *obstructionFlag,
+			OfflineHallCalls:   *offlineFlag,
+		})
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(2)
+		}
+		start := time.Now()
+		err = scenario.Run(c, *timeoutFlag)
+		c.Stop()
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %-12s %s: %v\n", scenario.Name, elapsed, err)
+		} else {
+			fmt.Printf("ok   %-12s %s\n", scenario.Name, elapsed)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"project/elevsim"
+	"strconv"
+)
+
+func main() {
+	portFlag := flag.Int("port", 15657, "Port to serve the elevator protocol on")
+	floorsFlag := flag.Int("floors", 4, "Number of floors")
+	travelFlag := flag.Duration("travel", elevsim.DEFAULT_TRAVEL_TIME, "Travel time between two floors")
+	startFlag := flag.Int("start", 0, "Floor the car starts at")
+	scriptFlag := flag.String("script", "", "File with scripted button presses, stop and obstruction events")
+	flag.Parse()
+
+	sim := elevsim.New(elevsim.Config{
+		NumFloors:  *floorsFlag,
+		TravelTime: *travelFlag,
+		StartFloor: *startFlag,
+	})
+
+	if *scriptFlag != "" {
+		file, err := os.Open(*scriptFlag)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		events, err := elevsim.ParseScript(file)
+		file.Close()
+		if err != nil {
+			fmt.Println("Error: script:", err)
+			return
+		}
+		go sim.RunScript(events)
+	}
+
+	fmt.Println("elevsim listening on port", *portFlag)
+	if err := sim.ListenAndServe(":" + strconv.Itoa(*portFlag)); err != nil {
+		fmt.Println("Error:", err)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+// Passiv monitor for hele flåten i terminalen. Lytter på peer- og meldingsportene uten å sende noe selv,
+// og viser heisene, hall requests, cab requests og hvor mange meldinger hver node sender.
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"project/datatypes"
+	"project/elevio"
+	"project/network/auth"
+	"project/network/bcast"
+	"project/network/peers"
+	"project/network/wire"
+	"project/requests"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	REFRESH_INTERVAL_MS = 250
+	RATE_WINDOW_S       = 5 // meldinger per sekund regnes ut over dette vinduet
+	STALE_AFTER_MS      = 1000
+)
+
+type nodeInfo struct {
+	msg      datatypes.NetworkMsg
+	lastSeen time.Time
+	received []time.Time // tidspunkt for meldinger innenfor RATE_WINDOW_S
+	total    uint64
+}
+
+type monitor struct {
+	nodes     map[string]*nodeInfo
+	peerList  []string
+	lastPeers peers.PeerUpdate
+	started   time.Time
+}
+
+func main() {
+	peerPortFlag := flag.Int("peerport", requests.PEER_PORT, "Port for peer heartbeats")
+	msgPortFlag := flag.Int("msgport", requests.MSG_PORT, "Port for status broadcasts")
+	authKeyFlag := flag.String("authkey", "", "Pre-shared key used by the fleet (empty if authentication is off)")
+	flag.Parse()
+
+	// samme registrering som i main, slik at både JSON og binære meldinger kan leses
+	bcast.RegisterTypeId(datatypes.NetworkMsg{}, datatypes.NETWORK_MSG_TYPE_ID)
+	bcast.RegisterCodec(datatypes.NetworkMsg{}, wire.NetworkMsgCodec{}, false)
+
+	if *authKeyFlag != "" {
+		// monitoren sender aldri, sender-ID brukes bare til Seal
+		auth.Configure([]byte(*authKeyFlag), "elevtop")
+	}
+
+	peerUpdateChan := make(chan peers.PeerUpdate)
+	receiveMessageChan := make(chan datatypes.NetworkMsg)
+	go peers.Receiver(*peerPortFlag, peerUpdateChan)
+	go bcast.Receiver(*msgPortFlag, receiveMessageChan)
+
+	m := monitor{nodes: make(map[string]*nodeInfo), started: time.Now()}
+	refreshTicker := time.NewTicker(REFRESH_INTERVAL_MS * time.Millisecond)
+
+	for {
+		select {
+		case p := <-peerUpdateChan:
+			m.peerList = p.Peers
+			m.lastPeers = p
+
+		case msg := <-receiveMessageChan:
+			if msg.SenderID == "" {
+				break
+			}
+			node, exists := m.nodes[msg.SenderID]
+			if !exists {
+				node = &nodeInfo{}
+				m.nodes[msg.SenderID] = node
+			}
+			node.msg = msg
+			node.lastSeen = time.Now()
+			node.received = append(node.received, node.lastSeen)
+			node.total++
+
+		case <-refreshTicker.C:
+			m.pruneRates()
+			fmt.Fprint(os.Stdout, "\033[H\033[2J"+m.render())
+		}
+	}
+}
+
+func (m *monitor) pruneRates() {
+	cutoff := time.Now().Add(-RATE_WINDOW_S * time.Second)
+	for _, node := range m.nodes {
+		i := 0
+		for i < len(node.received) && node.received[i].Before(cutoff) {
+			i++
+		}
+		node.received = node.received[i:]
+	}
+}
+
+func (m *monitor) sortedIDs() []string {
+	ids := make([]string, 0, len(m.nodes))
+	for id := range m.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (m *monitor) render() string {
+	var b strings.Builder
+	ids := m.sortedIDs()
+
+	fmt.Fprintf(&b, "elevtop  %s  up %s\n", time.Now().Format("15:04:05"), time.Since(m.started).Truncate(time.Second))
+	fmt.Fprintf(&b, "peers: %s", strings.Join(m.peerList, ", "))
+	if m.lastPeers.New != "" {
+		fmt.Fprintf(&b, "   last new: %s", m.lastPeers.New)
+	}
+	if len(m.lastPeers.Lost) > 0 {
+		fmt.Fprintf(&b, "   last lost: %s", strings.Join(m.lastPeers.Lost, ", "))
+	}
+	b.WriteString("\n")
+	if auth.Enabled() {
+		stats := auth.GetStats()
+		fmt.Fprintf(&b, "auth: accepted %d, rejected malformed %d / mac %d / replay %d / identity %d\n",
+			stats.Accepted, stats.RejectedMalformed, stats.RejectedMAC, stats.RejectedReplay, stats.RejectedIdentity)
+	}
+
+	if len(ids) == 0 {
+		b.WriteString("\nwaiting for status broadcasts ...\n")
+		return b.String()
+	}
+
+	b.WriteString("\nELEVATORS\n")
+	fmt.Fprintf(&b, "%-12s %5s %5s %-9s %-6s %-6s %-5s %-7s %8s %8s %7s\n",
+		"id", "floor", "dir", "behaviour", "avail", "fault", "peer", "proto", "msg/s", "total", "age")
+	window := time.Since(m.started).Seconds()
+	if window > RATE_WINDOW_S {
+		window = RATE_WINDOW_S
+	}
+	for _, id := range ids {
+		node := m.nodes[id]
+		age := time.Since(node.lastSeen)
+		ageString := age.Truncate(time.Millisecond).String()
+		if age > STALE_AFTER_MS*time.Millisecond {
+			ageString += " !"
+		}
+		fmt.Fprintf(&b, "%-12s %5d %5s %-9s %-6s %-6s %-5s %-7s %8.1f %8d %7s\n",
+			id, node.msg.Floor, dirToS(node.msg.Direction), behToS(node.msg.Behavior),
+			yesNo(node.msg.Available), faultToS(node.msg), yesNo(sliceContains(m.peerList, id)),
+			fmt.Sprintf("v%d", node.msg.Version()), float64(len(node.received))/window, node.total, ageString)
+	}
+
+	numFloors := 0
+	for _, id := range ids {
+		if len(m.nodes[id].msg.SenderHallRequests) > numFloors {
+			numFloors = len(m.nodes[id].msg.SenderHallRequests)
+		}
+	}
+
+	// hver node sitt syn på hall requests, slik at uenighet mellom nodene blir synlig
+	b.WriteString("\nHALL CALLS (up/down per node: - completed, U unassigned, A assigned, count, aware)\n")
+	fmt.Fprintf(&b, "%-6s", "floor")
+	for _, id := range ids {
+		fmt.Fprintf(&b, " %-27s", id)
+	}
+	b.WriteString("\n")
+	for f := numFloors - 1; f >= 0; f-- {
+		fmt.Fprintf(&b, "%-6d", f)
+		for _, id := range ids {
+			hall := m.nodes[id].msg.SenderHallRequests
+			cell := ""
+			if f < len(hall) {
+				cell = requestToS(hall[f][datatypes.BT_HallUP]) + " " + requestToS(hall[f][datatypes.BT_HallDOWN])
+			}
+			fmt.Fprintf(&b, " %-27s", cell)
+		}
+		b.WriteString("\n")
+	}
+
+	// cab requests slik hver node selv rapporterer dem
+	b.WriteString("\nCAB CALLS (own view per node)\n")
+	fmt.Fprintf(&b, "%-6s", "floor")
+	for _, id := range ids {
+		fmt.Fprintf(&b, " %-13s", id)
+	}
+	b.WriteString("\n")
+	for f := numFloors - 1; f >= 0; f-- {
+		fmt.Fprintf(&b, "%-6d", f)
+		for _, id := range ids {
+			cab := m.nodes[id].msg.AllCabRequests[id]
+			cell := ""
+			if f < len(cab) {
+				cell = requestToS(cab[f])
+			}
+			fmt.Fprintf(&b, " %-13s", cell)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func requestToS(request datatypes.RequestType) string {
+	switch request.State {
+	case datatypes.Unassigned:
+		return fmt.Sprintf("U%d[%d]", request.Count, len(request.AwareList))
+	case datatypes.Assigned:
+		return fmt.Sprintf("A%d[%d]", request.Count, len(request.AwareList))
+	}
+	return fmt.Sprintf("-%d", request.Count)
+}
+
+// Direction i NetworkMsg er en datatypes.Direction, selv om typen er elevio.MotorDirection
+func dirToS(dir elevio.MotorDirection) string {
+	switch datatypes.Direction(dir) {
+	case datatypes.DIR_UP:
+		return "up"
+	case datatypes.DIR_DOWN:
+		return "down"
+	}
+	return "stop"
+}
+
+func behToS(beh datatypes.ElevBehaviour) string {
+	switch beh {
+	case datatypes.DoorOpen:
+		return "doorOpen"
+	case datatypes.Moving:
+		return "moving"
+	}
+	return "idle"
+}
+
+// grunnen til at heisen er ute av drift, dersom den er det
+func faultToS(msg datatypes.NetworkMsg) string {
+	if msg.MotorFault {
+		return "FAULT"
+	}
+	if msg.Obstructed {
+		return "OBSTR"
+	}
+	return "ok"
+}
+
+func yesNo(val bool) string {
+	if val {
+		return "yes"
+	}
+	return "no"
+}
+
+func sliceContains(slice []string, elem string) bool {
+	for _, e := range slice {
+		if e == elem {
+			return true
+		}
+	}
+	return false
+}
@@ -6,7 +6,8 @@ import (
 	"time"
 )
 
-const N_FLOORS = 4
+const DEFAULT_N_FLOORS = 4 // antall etasjer settes ved oppstart, se -floors i main
+const MAX_N_FLOORS = 255   // største antall etasjer, både for -floors og i meldinger fra nettverket
 const N_BUTTONS = 3
 const N_HALL_BUTTONS = 2
 
@@ -30,7 +31,7 @@ type Elevator struct {
 	CurrentFloor int
 	Direction    Direction
 	State        ElevBehaviour
-	Orders       [N_FLOORS][N_BUTTONS]bool
+	Orders       [][N_BUTTONS]bool
 	Config       ElevatorConfig
 	StopActive   bool
 }
@@ -40,22 +41,26 @@ type NetElevator struct {
 	CurrentFloor int
 	Direction    elevio.MotorDirection
 	State        ElevBehaviour
-	Orders       [4][3]bool
+	Orders       [][N_BUTTONS]bool
 	Config       ElevatorConfig
 	StopActive   bool
 }
 
 type ElevSharedInfo struct {
 	Available    bool
+	MotorFault   bool
+	Obstructed   bool
 	Behaviour    ElevBehaviour
 	Direction    Direction
 	CurrentFloor int
+	Orders       [][N_BUTTONS]bool
+	StopActive   bool
 	Mutex        sync.RWMutex
 }
 
 type ElevatorContext struct {
-	HallRequests     [N_FLOORS][N_HALL_BUTTONS]RequestType
-	AllCabRequests   map[string][N_FLOORS]RequestType
+	HallRequests     [][N_HALL_BUTTONS]RequestType
+	AllCabRequests   map[string][]RequestType
 	UpdatedInfoElevs map[string]ElevatorInfo
 	PeerList         []string
 	LocalID          string
@@ -64,3 +69,8 @@ type ElevatorContext struct {
 type ElevatorConfig struct {
 	DoorOpenDuration time.Duration
 }
+
+// lager en tom ordretabell for et bygg med numFloors etasjer
+func NewOrders(numFloors int) [][N_BUTTONS]bool {
+	return make([][N_BUTTONS]bool, numFloors)
+}
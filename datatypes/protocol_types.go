@@ -0,0 +1,33 @@
+package datatypes
+
+// PROTOCOL_VERSION økes når NetworkMsg endres på en måte eldre noder må vite om.
+// Noder før versjonering sender ikke feltet, og leses som versjon 1. Alle versjoner så langt
+// kan snakke sammen, nye felter tas i bruk gjennom egenskapene under
+const (
+	PROTOCOL_VERSION = 2
+	LEGACY_PROTOCOL  = 1
+)
+
+// NetworkMsg sendes alltid under dette navnet, uavhengig av hva Go-typen heter.
+// Samme navn som bcast brukte før, slik at eldre noder fortsatt forstår meldingene
+const NETWORK_MSG_TYPE_ID = "datatypes.NetworkMsg"
+
+// egenskaper en node kan annonsere i NetworkMsg.Capabilities. En egenskap tas bare i bruk
+// når alle peers har annonsert den
+const (
+	CAP_BINARY_WIRE = "binary-wire" // kan dekode NetworkMsg kodet med wire.NetworkMsgCodec
+	CAP_CAB_RESTORE = "cab-restore" // svarer på NetworkMsg.CabRestoreRequest
+)
+
+// egenskapene denne versjonen av noden støtter
+func LocalCapabilities() []string {
+	return []string{CAP_BINARY_WIRE, CAP_CAB_RESTORE}
+}
+
+// gir versjonen til avsenderen, meldinger uten versjon kommer fra noder før versjonering
+func (msg NetworkMsg) Version() int {
+	if msg.ProtocolVersion == 0 {
+		return LEGACY_PROTOCOL
+	}
+	return msg.ProtocolVersion
+}
@@ -2,6 +2,7 @@ package datatypes
 
 import (
 	"project/elevio"
+	"strconv"
 )
 
 type RequestState int
@@ -12,6 +13,18 @@ const (
 	Assigned   RequestState = 2
 )
 
+func (s RequestState) String() string {
+	switch s {
+	case Completed:
+		return "Completed"
+	case Unassigned:
+		return "Unassigned"
+	case Assigned:
+		return "Assigned"
+	}
+	return "RequestState(" + strconv.Itoa(int(s)) + ")"
+}
+
 type RequestType struct {
 	State     RequestState
 	Count     int
@@ -20,17 +33,67 @@ type RequestType struct {
 
 type ElevatorInfo struct {
 	Available    bool
+	MotorFault   bool // heisen har ikke nådd en etasje innen MOVEMENT_TIMEOUT
+	Obstructed   bool // døren har vært blokkert lenger enn obstruction timeout, heisen er ute av drift
 	Behaviour    ElevBehaviour
 	Direction    Direction
 	CurrentFloor int
 }
 
 type NetworkMsg struct {
+	ProtocolVersion    int
+	Capabilities       []string
 	SenderID           string
 	Available          bool
+	MotorFault         bool
+	Obstructed         bool
+	CabRestoreRequest  bool // avsender har startet eller koblet seg til igjen, og ber om sine cab requests
+	CabRestoreReply    bool // svar på CabRestoreRequest, AllCabRequests har avsenderens syn på alle cab requests
 	Behavior           ElevBehaviour
 	Direction          elevio.MotorDirection
 	Floor              int
-	SenderHallRequests [N_FLOORS][N_HALL_BUTTONS]RequestType
-	AllCabRequests     map[string][N_FLOORS]RequestType
+	SenderHallRequests [][N_HALL_BUTTONS]RequestType
+	AllCabRequests     map[string][]RequestType
+}
+
+func NewHallRequests(numFloors int) [][N_HALL_BUTTONS]RequestType {
+	return make([][N_HALL_BUTTONS]RequestType, numFloors)
+}
+
+func NewCabRequests(numFloors int) []RequestType {
+	return make([]RequestType, numFloors)
+}
+
+// kopierer hall requests, slik at tabellen kan sendes til en annen goroutine uten å deles
+func CopyHallRequests(hallRequests [][N_HALL_BUTTONS]RequestType) [][N_HALL_BUTTONS]RequestType {
+	copied := make([][N_HALL_BUTTONS]RequestType, len(hallRequests))
+	for f := range hallRequests {
+		for b := range hallRequests[f] {
+			copied[f][b] = copyRequest(hallRequests[f][b])
+		}
+	}
+	return copied
+}
+
+func CopyCabRequests(cabRequests []RequestType) []RequestType {
+	copied := make([]RequestType, len(cabRequests))
+	for f := range cabRequests {
+		copied[f] = copyRequest(cabRequests[f])
+	}
+	return copied
+}
+
+func CopyAllCabRequests(allCabRequests map[string][]RequestType) map[string][]RequestType {
+	copied := make(map[string][]RequestType, len(allCabRequests))
+	for ID, cabRequests := range allCabRequests {
+		copied[ID] = CopyCabRequests(cabRequests)
+	}
+	return copied
+}
+
+func copyRequest(request RequestType) RequestType {
+	if request.AwareList != nil {
+		request.AwareList = append([]string{}, request.AwareList...)
+	}
+	return request
 }
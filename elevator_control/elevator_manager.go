@@ -1,72 +1,157 @@
 package elevator_control
 
 import (
+	"project/clock"
 	"project/datatypes"
 	"project/elevio"
 	"time"
 )
 
-var sharedInfoElevs datatypes.ElevSharedInfo
+// Shared er tilstanden fsm deler med resten av noden. Hver node i samme prosess, f.eks. i cluster,
+// må ha sin egen
+type Shared struct {
+	info datatypes.ElevSharedInfo
+}
+
+func NewShared() *Shared {
+	return &Shared{}
+}
+
+// brukes av funksjonene under og når ingen Shared er gitt i konfigurasjonen
+var defaultShared = NewShared()
+
+func Default() *Shared {
+	return defaultShared
+}
+
+// gir s, eller den felles instansen dersom s er nil
+func OrDefault(s *Shared) *Shared {
+	if s == nil {
+		return defaultShared
+	}
+	return s
+}
 
 // henter informasjon om heis, returnerer en kopi av heisens tilstand
-func GetInfoElev() datatypes.ElevatorInfo {
-	sharedInfoElevs.Mutex.RLock()
-	defer sharedInfoElevs.Mutex.RUnlock()
+func (s *Shared) GetInfoElev() datatypes.ElevatorInfo {
+	s.info.Mutex.RLock()
+	defer s.info.Mutex.RUnlock()
 
 	return datatypes.ElevatorInfo{
-		Available:    sharedInfoElevs.Available,
-		Behaviour:    sharedInfoElevs.Behaviour,
-		Direction:    sharedInfoElevs.Direction,
-		CurrentFloor: sharedInfoElevs.CurrentFloor,
+		Available:    s.info.Available,
+		MotorFault:   s.info.MotorFault,
+		Obstructed:   s.info.Obstructed,
+		Behaviour:    s.info.Behaviour,
+		Direction:    s.info.Direction,
+		CurrentFloor: s.info.CurrentFloor,
 	}
 }
 
 // oppdaterer info om heis
-func UpdateInfoElev(elevator datatypes.Elevator) {
-	sharedInfoElevs.Mutex.Lock()
-	defer sharedInfoElevs.Mutex.Unlock()
+func (s *Shared) UpdateInfoElev(elevator datatypes.Elevator) {
+	s.info.Mutex.Lock()
+	defer s.info.Mutex.Unlock()
 
-	sharedInfoElevs.Behaviour = elevator.State
-	sharedInfoElevs.Direction = elevator.Direction
-	sharedInfoElevs.CurrentFloor = elevator.CurrentFloor
+	s.info.Behaviour = elevator.State
+	s.info.Direction = elevator.Direction
+	s.info.CurrentFloor = elevator.CurrentFloor
+	s.info.Orders = append(s.info.Orders[:0], elevator.Orders...)
+	s.info.StopActive = elevator.StopActive
+}
+
+// henter hele tilstanden til den lokale heisen slik fsm sist oppdaterte den, inkludert bestillinger
+func (s *Shared) GetElevator() datatypes.Elevator {
+	s.info.Mutex.RLock()
+	defer s.info.Mutex.RUnlock()
+
+	return datatypes.Elevator{
+		CurrentFloor: s.info.CurrentFloor,
+		Direction:    s.info.Direction,
+		State:        s.info.Behaviour,
+		Orders:       append([][datatypes.N_BUTTONS]bool{}, s.info.Orders...),
+		StopActive:   s.info.StopActive,
+	}
 }
 
 // endrer tilgjengelighet til heisen basert på val
+func (s *Shared) SetElevAvailability(val bool) {
+	s.info.Mutex.Lock()
+	defer s.info.Mutex.Unlock()
+
+	s.info.Available = val
+}
+
+// markerer motorfeil, heisen er da ikke tilgjengelig for hall requests før feilen er borte
+func (s *Shared) SetMotorFault(val bool) {
+	s.info.Mutex.Lock()
+	defer s.info.Mutex.Unlock()
+
+	s.info.MotorFault = val
+}
+
+// markerer at døren har vært blokkert for lenge, heisen er da ute av drift til obstruction er borte
+func (s *Shared) SetObstructed(val bool) {
+	s.info.Mutex.Lock()
+	defer s.info.Mutex.Unlock()
+
+	s.info.Obstructed = val
+}
+
+func GetInfoElev() datatypes.ElevatorInfo {
+	return defaultShared.GetInfoElev()
+}
+
+func UpdateInfoElev(elevator datatypes.Elevator) {
+	defaultShared.UpdateInfoElev(elevator)
+}
+
+func GetElevator() datatypes.Elevator {
+	return defaultShared.GetElevator()
+}
+
 func SetElevAvailability(val bool) {
-	sharedInfoElevs.Mutex.Lock()
-	defer sharedInfoElevs.Mutex.Unlock()
+	defaultShared.SetElevAvailability(val)
+}
+
+func SetMotorFault(val bool) {
+	defaultShared.SetMotorFault(val)
+}
 
-	sharedInfoElevs.Available = val
+func SetObstructed(val bool) {
+	defaultShared.SetObstructed(val)
 }
 
 // initialiserer heisen, vet da ikke hvilken etasje den er i - må få gyldig etasje
-func InitElevator(chanFloorSensor <-chan int) datatypes.Elevator {
-	elevio.SetDoorOpenLamp(false) // slår av lampe for door open
+func InitElevator(driver elevio.ElevatorDriver, numFloors int, chanFloorSensor <-chan int) datatypes.Elevator {
+	driver.SetDoorOpenLamp(false) // slår av lampe for door open
 
 	// slår av alle etasjelys
-	for f := 0; f < datatypes.N_FLOORS; f++ {
+	for f := 0; f < numFloors; f++ {
 		for b := 0; b < datatypes.N_BUTTONS; b++ {
-			elevio.SetButtonLamp(elevio.ButtonType(b), f, false)
+			driver.SetButtonLamp(elevio.ButtonType(b), f, false)
 		}
 	}
 
-	elevio.SetMotorDirection(elevio.MD_Down) // setter retning ned for å finne gyldig etasje
+	driver.SetMotorDirection(elevio.MD_Down) // setter retning ned for å finne gyldig etasje
 	currentFloor := <-chanFloorSensor        // venter på etasje sensor til å angi en etasje
-	elevio.SetMotorDirection(elevio.MD_Stop) // stopper heisen i den funnede etasjen
-	elevio.SetFloorIndicator(currentFloor)   // oppdaterer heisens etasje med lampe
+	driver.SetMotorDirection(elevio.MD_Stop) // stopper heisen i den funnede etasjen
+	driver.SetFloorIndicator(currentFloor)   // oppdaterer heisens etasje med lampe
 
-	return datatypes.Elevator{CurrentFloor: currentFloor, Direction: datatypes.DIR_STOP, Orders: [datatypes.N_FLOORS][datatypes.N_BUTTONS]bool{}, State: datatypes.Idle}
+	return datatypes.Elevator{CurrentFloor: currentFloor, Direction: datatypes.DIR_STOP, Orders: datatypes.NewOrders(numFloors), State: datatypes.Idle}
 }
 
 // starter/nullstiller en timer til et nytt antall sekunder
-func RestartTimer(timer *time.Timer, sec int) {
+func RestartTimer(timer clock.Timer, sec int) {
 	timer.Reset(time.Duration(sec) * time.Second)
 }
 
-// stopper en aktiv timer
-func KillTimer(timer *time.Timer) {
+// stopper en timer og tømmer kanalen, blokkerer ikke dersom timeren allerede er stoppet
+func KillTimer(timer clock.Timer) {
 	if !timer.Stop() {
-		<-timer.C
+		select {
+		case <-timer.C():
+		default:
+		}
 	}
 }
 
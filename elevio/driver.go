@@ -0,0 +1,47 @@
+package elevio
+
+// ElevatorDriver is the hardware seen from the FSM and the request loop.
+// TCPDriver talks to the elevator server or simulator, FakeDriver keeps everything in memory.
+type ElevatorDriver interface {
+	SetMotorDirection(dir MotorDirection)
+	SetButtonLamp(button ButtonType, floor int, value bool)
+	SetFloorIndicator(floor int)
+	SetDoorOpenLamp(value bool)
+	SetStopLamp(value bool)
+
+	PollButtons(receiver chan<- ButtonEvent)
+	PollFloorSensor(receiver chan<- int)
+	PollStopButton(receiver chan<- bool)
+	PollObstructionSwitch(receiver chan<- bool)
+
+	GetButton(button ButtonType, floor int) bool
+	GetFloor() int
+	GetStop() bool
+	GetObstruction() bool
+}
+
+// TCPDriver uses the package level connection set up by Init
+type TCPDriver struct{}
+
+func NewTCPDriver(addr string, numFloors int) TCPDriver {
+	Init(addr, numFloors)
+	return TCPDriver{}
+}
+
+func (TCPDriver) SetMotorDirection(dir MotorDirection) { SetMotorDirection(dir) }
+func (TCPDriver) SetButtonLamp(button ButtonType, floor int, value bool) {
+	SetButtonLamp(button, floor, value)
+}
+func (TCPDriver) SetFloorIndicator(floor int) { SetFloorIndicator(floor) }
+func (TCPDriver) SetDoorOpenLamp(value bool)  { SetDoorOpenLamp(value) }
+func (TCPDriver) SetStopLamp(value bool)      { SetStopLamp(value) }
+
+func (TCPDriver) PollButtons(receiver chan<- ButtonEvent)    { PollButtons(receiver) }
+func (TCPDriver) PollFloorSensor(receiver chan<- int)        { PollFloorSensor(receiver) }
+func (TCPDriver) PollStopButton(receiver chan<- bool)        { PollStopButton(receiver) }
+func (TCPDriver) PollObstructionSwitch(receiver chan<- bool) { PollObstructionSwitch(receiver) }
+
+func (TCPDriver) GetButton(button ButtonType, floor int) bool { return GetButton(button, floor) }
+func (TCPDriver) GetFloor() int                               { return GetFloor() }
+func (TCPDriver) GetStop() bool                               { return GetStop() }
+func (TCPDriver) GetObstruction() bool                        { return GetObstruction() }
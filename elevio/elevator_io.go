@@ -1,8 +1,8 @@
 package elevio
 
 import (
-	"fmt"
 	"net"
+	"project/logging"
 	"sync"
 	"time"
 )
@@ -14,6 +14,8 @@ var _numFloors int = 4
 var _mtx sync.Mutex
 var _conn net.Conn
 
+var log = logging.New("elevio")
+
 type MotorDirection int
 
 const (
@@ -37,7 +39,7 @@ type ButtonEvent struct {
 
 func Init(addr string, numFloors int) {
 	if _initialized {
-		fmt.Println("Driver already initialized!")
+		log.Warn("Driver already initialized")
 		return
 	}
 	_numFloors = numFloors
@@ -45,8 +47,10 @@ func Init(addr string, numFloors int) {
 	var err error
 	_conn, err = net.Dial("tcp", addr)
 	if err != nil {
+		log.Error("Could not connect to elevator server", "addr", addr, "err", err)
 		panic(err.Error())
 	}
+	log.Info("Connected to elevator server", "addr", addr, "floors", numFloors)
 	_initialized = true
 }
 
@@ -71,12 +75,28 @@ func SetStopLamp(value bool) {
 }
 
 func PollButtons(receiver chan<- ButtonEvent) {
-	prev := make([][3]bool, _numFloors)
+	pollButtons(GetButton, _numFloors, receiver)
+}
+
+func PollFloorSensor(receiver chan<- int) {
+	pollFloorSensor(GetFloor, receiver)
+}
+
+func PollStopButton(receiver chan<- bool) {
+	pollSwitch(GetStop, receiver)
+}
+
+func PollObstructionSwitch(receiver chan<- bool) {
+	pollSwitch(GetObstruction, receiver)
+}
+
+func pollButtons(getButton func(ButtonType, int) bool, numFloors int, receiver chan<- ButtonEvent) {
+	prev := make([][3]bool, numFloors)
 	for {
 		time.Sleep(_pollRate)
-		for f := 0; f < _numFloors; f++ {
+		for f := 0; f < numFloors; f++ {
 			for b := ButtonType(0); b < 3; b++ {
-				v := GetButton(b, f)
+				v := getButton(b, f)
 				if v != prev[f][b] && v != false {
 					receiver <- ButtonEvent{f, ButtonType(b)}
 				}
@@ -86,11 +106,11 @@ func PollButtons(receiver chan<- ButtonEvent) {
 	}
 }
 
-func PollFloorSensor(receiver chan<- int) {
+func pollFloorSensor(getFloor func() int, receiver chan<- int) {
 	prev := -1
 	for {
 		time.Sleep(_pollRate)
-		v := GetFloor()
+		v := getFloor()
 		if v != prev && v != -1 {
 			receiver <- v
 		}
@@ -98,23 +118,11 @@ func PollFloorSensor(receiver chan<- int) {
 	}
 }
 
-func PollStopButton(receiver chan<- bool) {
-	prev := false
-	for {
-		time.Sleep(_pollRate)
-		v := GetStop()
-		if v != prev {
-			receiver <- v
-		}
-		prev = v
-	}
-}
-
-func PollObstructionSwitch(receiver chan<- bool) {
+func pollSwitch(get func() bool, receiver chan<- bool) {
 	prev := false
 	for {
 		time.Sleep(_pollRate)
-		v := GetObstruction()
+		v := get()
 		if v != prev {
 			receiver <- v
 		}
@@ -0,0 +1,176 @@
+package elevio
+
+import "sync"
+
+// FakeDriver is an in-memory ElevatorDriver. Inputs are set with Press/Release, SetFloor,
+// SetStop and SetObstruction, and the lamps and motor can be read back for assertions.
+// The car does not move by itself; the caller moves it with SetFloor.
+type FakeDriver struct {
+	mtx       sync.Mutex
+	numFloors int
+
+	buttons     [][3]bool
+	floor       int
+	stop        bool
+	obstruction bool
+
+	motorDir       MotorDirection
+	buttonLamps    [][3]bool
+	floorIndicator int
+	doorOpenLamp   bool
+	stopLamp       bool
+}
+
+func NewFakeDriver(numFloors int) *FakeDriver {
+	return &FakeDriver{
+		numFloors:      numFloors,
+		buttons:        make([][3]bool, numFloors),
+		buttonLamps:    make([][3]bool, numFloors),
+		floor:          -1,
+		floorIndicator: -1,
+	}
+}
+
+func (d *FakeDriver) SetMotorDirection(dir MotorDirection) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.motorDir = dir
+}
+
+func (d *FakeDriver) SetButtonLamp(button ButtonType, floor int, value bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if floor >= 0 && floor < d.numFloors {
+		d.buttonLamps[floor][button] = value
+	}
+}
+
+func (d *FakeDriver) SetFloorIndicator(floor int) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.floorIndicator = floor
+}
+
+func (d *FakeDriver) SetDoorOpenLamp(value bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.doorOpenLamp = value
+}
+
+func (d *FakeDriver) SetStopLamp(value bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.stopLamp = value
+}
+
+func (d *FakeDriver) PollButtons(receiver chan<- ButtonEvent) {
+	pollButtons(d.GetButton, d.numFloors, receiver)
+}
+
+func (d *FakeDriver) PollFloorSensor(receiver chan<- int) {
+	pollFloorSensor(d.GetFloor, receiver)
+}
+
+func (d *FakeDriver) PollStopButton(receiver chan<- bool) {
+	pollSwitch(d.GetStop, receiver)
+}
+
+func (d *FakeDriver) PollObstructionSwitch(receiver chan<- bool) {
+	pollSwitch(d.GetObstruction, receiver)
+}
+
+func (d *FakeDriver) GetButton(button ButtonType, floor int) bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if floor < 0 || floor >= d.numFloors {
+		return false
+	}
+	return d.buttons[floor][button]
+}
+
+func (d *FakeDriver) GetFloor() int {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.floor
+}
+
+func (d *FakeDriver) GetStop() bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.stop
+}
+
+func (d *FakeDriver) GetObstruction() bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.obstruction
+}
+
+// Press holds a button down until Release is called
+func (d *FakeDriver) Press(button ButtonType, floor int) {
+	d.setButton(button, floor, true)
+}
+
+func (d *FakeDriver) Release(button ButtonType, floor int) {
+	d.setButton(button, floor, false)
+}
+
+func (d *FakeDriver) setButton(button ButtonType, floor int, value bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if floor >= 0 && floor < d.numFloors {
+		d.buttons[floor][button] = value
+	}
+}
+
+// SetFloor sets the floor sensor, -1 means between floors
+func (d *FakeDriver) SetFloor(floor int) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.floor = floor
+}
+
+func (d *FakeDriver) SetStop(value bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.stop = value
+}
+
+func (d *FakeDriver) SetObstruction(value bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.obstruction = value
+}
+
+func (d *FakeDriver) MotorDirection() MotorDirection {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.motorDir
+}
+
+func (d *FakeDriver) ButtonLamp(button ButtonType, floor int) bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if floor < 0 || floor >= d.numFloors {
+		return false
+	}
+	return d.buttonLamps[floor][button]
+}
+
+func (d *FakeDriver) FloorIndicator() int {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.floorIndicator
+}
+
+func (d *FakeDriver) DoorOpenLamp() bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.doorOpenLamp
+}
+
+func (d *FakeDriver) StopLamp() bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.stopLamp
+}
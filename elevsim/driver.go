@@ -0,0 +1,156 @@
+package elevsim
+
+// Driver kobler en node til simulatoren i samme prosess. Kommandoene går gjennom de samme
+// operasjonene som TCP-protokollen, bare uten socket, slik at flere noder kan kjøres i én prosess.
+
+import (
+	"project/elevio"
+	"sync"
+	"time"
+)
+
+const DRIVER_POLL_RATE = 20 * time.Millisecond
+
+type Driver struct {
+	sim       *Simulator
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewDriver lager en driver for s. Flere drivere kan lages etter hverandre, f.eks. når en node
+// startes på nytt, men bare én bør være åpen om gangen
+func (s *Simulator) NewDriver() *Driver {
+	return &Driver{sim: s, done: make(chan struct{})}
+}
+
+// Close stopper pollingen, og kommandoer som kommer etterpå ignoreres
+func (d *Driver) Close() {
+	d.closeOnce.Do(func() { close(d.done) })
+}
+
+func (d *Driver) closed() bool {
+	select {
+	case <-d.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *Driver) command(in [4]byte) [4]byte {
+	if d.closed() {
+		return [4]byte{}
+	}
+	reply, _ := d.sim.handle(in)
+	return reply
+}
+
+func (d *Driver) SetMotorDirection(dir elevio.MotorDirection) {
+	d.command([4]byte{cmdMotorDirection, byte(dir), 0, 0})
+}
+
+func (d *Driver) SetButtonLamp(button elevio.ButtonType, floor int, value bool) {
+	d.command([4]byte{cmdButtonLamp, byte(button), byte(floor), toByte(value)})
+}
+
+func (d *Driver) SetFloorIndicator(floor int) {
+	d.command([4]byte{cmdFloorIndicator, byte(floor), 0, 0})
+}
+
+func (d *Driver) SetDoorOpenLamp(value bool) {
+	d.command([4]byte{cmdDoorOpenLamp, toByte(value), 0, 0})
+}
+
+func (d *Driver) SetStopLamp(value bool) {
+	d.command([4]byte{cmdStopLamp, toByte(value), 0, 0})
+}
+
+func (d *Driver) GetButton(button elevio.ButtonType, floor int) bool {
+	return d.command([4]byte{cmdGetButton, byte(button), byte(floor), 0})[1] != 0
+}
+
+func (d *Driver) GetFloor() int {
+	reply := d.command([4]byte{cmdGetFloor, 0, 0, 0})
+	if reply[1] == 0 {
+		return -1
+	}
+	return int(reply[2])
+}
+
+func (d *Driver) GetStop() bool {
+	return d.command([4]byte{cmdGetStop, 0, 0, 0})[1] != 0
+}
+
+func (d *Driver) GetObstruction() bool {
+	return d.command([4]byte{cmdGetObstruction, 0, 0, 0})[1] != 0
+}
+
+// pollingen gjør det samme som i elevio, men returnerer når driveren lukkes
+
+func (d *Driver) PollButtons(receiver chan<- elevio.ButtonEvent) {
+	numFloors := d.sim.config.NumFloors
+	prev := make([][nButtons]bool, numFloors)
+	for d.sleep() {
+		for f := 0; f < numFloors; f++ {
+			for b := elevio.ButtonType(0); b < nButtons; b++ {
+				v := d.GetButton(b, f)
+				if v && !prev[f][b] {
+					select {
+					case receiver <- elevio.ButtonEvent{Floor: f, Button: b}:
+					case <-d.done:
+						return
+					}
+				}
+				prev[f][b] = v
+			}
+		}
+	}
+}
+
+func (d *Driver) PollFloorSensor(receiver chan<- int) {
+	prev := -1
+	for d.sleep() {
+		v := d.GetFloor()
+		if v != prev && v != -1 {
+			select {
+			case receiver <- v:
+			case <-d.done:
+				return
+			}
+		}
+		prev = v
+	}
+}
+
+func (d *Driver) PollStopButton(receiver chan<- bool) {
+	d.pollSwitch(d.GetStop, receiver)
+}
+
+func (d *Driver) PollObstructionSwitch(receiver chan<- bool) {
+	d.pollSwitch(d.GetObstruction, receiver)
+}
+
+func (d *Driver) pollSwitch(get func() bool, receiver chan<- bool) {
+	prev := false
+	for d.sleep() {
+		v := get()
+		if v != prev {
+			select {
+			case receiver <- v:
+			case <-d.done:
+				return
+			}
+		}
+		prev = v
+	}
+}
+
+// venter én poll-periode, returnerer false dersom driveren er lukket
+func (d *Driver) sleep() bool {
+	select {
+	case <-time.After(DRIVER_POLL_RATE):
+		return true
+	case <-d.done:
+		return false
+	}
+}
@@ -0,0 +1,117 @@
+package elevsim
+
+// Skript med hendelser for simulatoren, én hendelse per linje:
+//
+//	<tid> press <hall_up|hall_down|cab> <etasje>
+//	<tid> stop <on|off>
+//	<tid> obstruction <on|off>
+//
+// der tid er en Go duration regnet fra skriptet starter, f.eks. 1500ms eller 2s. Linjer som starter med # hoppes over.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"project/elevio"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ScriptEvent struct {
+	At     time.Duration
+	Action string
+	Button elevio.ButtonType
+	Floor  int
+	Value  bool
+}
+
+const (
+	ACTION_PRESS       = "press"
+	ACTION_STOP        = "stop"
+	ACTION_OBSTRUCTION = "obstruction"
+)
+
+func ParseScript(r io.Reader) ([]ScriptEvent, error) {
+	events := []ScriptEvent{}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		event, err := parseEvent(strings.Fields(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+	return events, nil
+}
+
+func parseEvent(fields []string) (ScriptEvent, error) {
+	if len(fields) < 3 {
+		return ScriptEvent{}, fmt.Errorf("expected '<time> <action> <args>', got %q", strings.Join(fields, " "))
+	}
+	at, err := time.ParseDuration(fields[0])
+	if err != nil {
+		return ScriptEvent{}, err
+	}
+	event := ScriptEvent{At: at, Action: fields[1]}
+
+	switch event.Action {
+	case ACTION_PRESS:
+		if len(fields) != 4 {
+			return ScriptEvent{}, fmt.Errorf("press needs a button and a floor")
+		}
+		switch fields[2] {
+		case "hall_up":
+			event.Button = elevio.BT_HallUp
+		case "hall_down":
+			event.Button = elevio.BT_HallDown
+		case "cab":
+			event.Button = elevio.BT_Cab
+		default:
+			return ScriptEvent{}, fmt.Errorf("unknown button %q", fields[2])
+		}
+		event.Floor, err = strconv.Atoi(fields[3])
+		if err != nil {
+			return ScriptEvent{}, err
+		}
+	case ACTION_STOP, ACTION_OBSTRUCTION:
+		switch fields[2] {
+		case "on":
+			event.Value = true
+		case "off":
+			event.Value = false
+		default:
+			return ScriptEvent{}, fmt.Errorf("expected on or off, got %q", fields[2])
+		}
+	default:
+		return ScriptEvent{}, fmt.Errorf("unknown action %q", event.Action)
+	}
+	return event, nil
+}
+
+// RunScript utfører hendelsene til riktig tid, blokkerer til siste hendelse er utført
+func (s *Simulator) RunScript(events []ScriptEvent) {
+	start := time.Now()
+	for _, event := range events {
+		time.Sleep(time.Until(start.Add(event.At)))
+		switch event.Action {
+		case ACTION_PRESS:
+			s.Press(event.Button, event.Floor, defaultButtonPress)
+		case ACTION_STOP:
+			s.SetStop(event.Value)
+		case ACTION_OBSTRUCTION:
+			s.SetObstruction(event.Value)
+		}
+	}
+}
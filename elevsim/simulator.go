@@ -0,0 +1,250 @@
+package elevsim
+
+// Elevator simulator serving the same 4-byte TCP protocol as the course simulator, so nodes
+// can run against it through elevio without any external process.
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"project/elevio"
+	"sync"
+	"time"
+)
+
+const (
+	cmdReload          = 0
+	cmdMotorDirection  = 1
+	cmdButtonLamp      = 2
+	cmdFloorIndicator  = 3
+	cmdDoorOpenLamp    = 4
+	cmdStopLamp        = 5
+	cmdGetButton       = 6
+	cmdGetFloor        = 7
+	cmdGetStop         = 8
+	cmdGetObstruction  = 9
+	nButtons           = 3
+	minSensorWidth     = 0.05                  // del av en etasje der sensoren er aktiv
+	minSensorTime      = 60 * time.Millisecond // sensoren må være aktiv lenger enn elevio sin poll rate
+	defaultButtonPress = 100 * time.Millisecond
+)
+
+const DEFAULT_TRAVEL_TIME = 2 * time.Second
+
+type Config struct {
+	NumFloors  int
+	TravelTime time.Duration // tid mellom to etasjer
+	StartFloor int
+}
+
+type Simulator struct {
+	mtx    sync.Mutex
+	config Config
+
+	position   float64 // i etasjer, 0 er nederste etasje
+	motorDir   elevio.MotorDirection
+	lastUpdate time.Time
+
+	buttons     [][nButtons]bool
+	stop        bool
+	obstruction bool
+
+	buttonLamps    [][nButtons]bool
+	floorIndicator int
+	doorOpenLamp   bool
+	stopLamp       bool
+}
+
+func New(config Config) *Simulator {
+	if config.NumFloors <= 0 {
+		config.NumFloors = 4
+	}
+	if config.TravelTime <= 0 {
+		config.TravelTime = DEFAULT_TRAVEL_TIME
+	}
+	if config.StartFloor < 0 || config.StartFloor >= config.NumFloors {
+		config.StartFloor = 0
+	}
+	return &Simulator{
+		config:      config,
+		position:    float64(config.StartFloor),
+		lastUpdate:  time.Now(),
+		buttons:     make([][nButtons]bool, config.NumFloors),
+		buttonLamps: make([][nButtons]bool, config.NumFloors),
+	}
+}
+
+func (s *Simulator) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve tar imot klienter på listener, hver klient får sin egen goroutine
+func (s *Simulator) Serve(listener net.Listener) error {
+	defer listener.Close()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Simulator) handleConn(conn net.Conn) {
+	defer conn.Close()
+	var in [4]byte
+	for {
+		if _, err := io.ReadFull(conn, in[:]); err != nil {
+			if err != io.EOF {
+				fmt.Println("elevsim: connection error:", err)
+			}
+			return
+		}
+		reply, hasReply := s.handle(in)
+		if !hasReply {
+			continue
+		}
+		if _, err := conn.Write(reply[:]); err != nil {
+			fmt.Println("elevsim: connection error:", err)
+			return
+		}
+	}
+}
+
+// utfører en kommando, kommandoene 6-9 gir svar tilbake til klienten
+func (s *Simulator) handle(in [4]byte) ([4]byte, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.advance()
+
+	switch in[0] {
+	case cmdReload:
+	case cmdMotorDirection:
+		s.motorDir = elevio.MotorDirection(int8(in[1]))
+	case cmdButtonLamp:
+		if s.validButton(int(in[1]), int(in[2])) {
+			s.buttonLamps[in[2]][in[1]] = in[3] != 0
+		}
+	case cmdFloorIndicator:
+		s.floorIndicator = int(in[1])
+	case cmdDoorOpenLamp:
+		s.doorOpenLamp = in[1] != 0
+	case cmdStopLamp:
+		s.stopLamp = in[1] != 0
+	case cmdGetButton:
+		value := s.validButton(int(in[1]), int(in[2])) && s.buttons[in[2]][in[1]]
+		return [4]byte{cmdGetButton, toByte(value), 0, 0}, true
+	case cmdGetFloor:
+		floor := s.sensorFloor()
+		if floor == -1 {
+			return [4]byte{cmdGetFloor, 0, 0, 0}, true
+		}
+		return [4]byte{cmdGetFloor, 1, byte(floor), 0}, true
+	case cmdGetStop:
+		return [4]byte{cmdGetStop, toByte(s.stop), 0, 0}, true
+	case cmdGetObstruction:
+		return [4]byte{cmdGetObstruction, toByte(s.obstruction), 0, 0}, true
+	default:
+		fmt.Println("elevsim: unknown command", in)
+	}
+	return [4]byte{}, false
+}
+
+// flytter heisen så langt den har kjørt siden forrige oppdatering, stopper ved endene av sjakten
+func (s *Simulator) advance() {
+	now := time.Now()
+	elapsed := now.Sub(s.lastUpdate)
+	s.lastUpdate = now
+
+	s.position += float64(s.motorDir) * float64(elapsed) / float64(s.config.TravelTime)
+	s.position = math.Max(0, math.Min(s.position, float64(s.config.NumFloors-1)))
+}
+
+func (s *Simulator) sensorFloor() int {
+	width := math.Max(minSensorWidth, float64(minSensorTime)/float64(s.config.TravelTime))
+	nearest := math.Round(s.position)
+	if math.Abs(s.position-nearest) > width/2 {
+		return -1
+	}
+	return int(nearest)
+}
+
+func (s *Simulator) validButton(button int, floor int) bool {
+	return button >= 0 && button < nButtons && floor >= 0 && floor < s.config.NumFloors
+}
+
+// Press trykker inn en knapp i duration, slik at elevio rekker å se trykket
+func (s *Simulator) Press(button elevio.ButtonType, floor int, duration time.Duration) {
+	s.setButton(button, floor, true)
+	time.AfterFunc(duration, func() { s.setButton(button, floor, false) })
+}
+
+func (s *Simulator) setButton(button elevio.ButtonType, floor int, value bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.validButton(int(button), floor) {
+		s.buttons[floor][button] = value
+	}
+}
+
+func (s *Simulator) SetStop(value bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.stop = value
+}
+
+func (s *Simulator) SetObstruction(value bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.obstruction = value
+}
+
+// Position gir heisens posisjon i etasjer, f.eks. 1.5 er midt mellom 2. og 3. etasje
+func (s *Simulator) Position() float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.advance()
+	return s.position
+}
+
+func (s *Simulator) MotorDirection() elevio.MotorDirection {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.motorDir
+}
+
+func (s *Simulator) ButtonLamp(button elevio.ButtonType, floor int) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.validButton(int(button), floor) && s.buttonLamps[floor][button]
+}
+
+func (s *Simulator) DoorOpenLamp() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.doorOpenLamp
+}
+
+func (s *Simulator) StopLamp() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.stopLamp
+}
+
+func (s *Simulator) FloorIndicator() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.floorIndicator
+}
+
+func toByte(a bool) byte {
+	if a {
+		return 1
+	}
+	return 0
+}
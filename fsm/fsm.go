@@ -1,63 +1,106 @@
 package fsm
 
 import (
+	"project/clock"
 	"project/datatypes"
 	"project/elevator_control"
 	"project/elevio"
+	"project/journal"
+	"project/logging"
 	"project/requests"
-	"time"
 )
 
+var log = logging.New("fsm")
+
 const DOOR_OPEN_DURATION = 3
 const MOVEMENT_TIMEOUT = 4
+const OBSTRUCTION_TIMEOUT = 10 // sekunder døren kan være blokkert før heisen tas ut av drift
+
+// innstillinger for RunElevFSM
+type FSMConfig struct {
+	NumFloors          int
+	ObstructionTimeout int                      // sekunder, 0 gir OBSTRUCTION_TIMEOUT
+	Clock              clock.Clock              // nil gir vanlig tid
+	Journal            *journal.Recorder        // skriver timere som går ut, kan være nil
+	Shared             *elevator_control.Shared // nil gir den felles instansen i elevator_control
+	Done               <-chan struct{}          // RunElevFSM returnerer når kanalen lukkes, kan være nil
+}
 
-func RunElevFSM(reqChan <-chan [datatypes.N_FLOORS][datatypes.N_BUTTONS]bool,
+func RunElevFSM(driver elevio.ElevatorDriver, config FSMConfig, reqChan <-chan [][datatypes.N_BUTTONS]bool,
 	completedReqChan chan<- datatypes.ButtonEvent) {
 
+	numFloors := config.NumFloors
+	clk := clock.OrReal(config.Clock)
+	recorder := config.Journal
+	shared := elevator_control.OrDefault(config.Shared)
+	obstructionTimeout := config.ObstructionTimeout
+	if obstructionTimeout <= 0 {
+		obstructionTimeout = OBSTRUCTION_TIMEOUT
+	}
+
 	floorSensorChan := make(chan int)
 	obstructionChan := make(chan bool) // tar inn hvorvidt obstruction eller ikke
+	stopButtonChan := make(chan bool)
 
-	go elevio.PollFloorSensor(floorSensorChan)
-	go elevio.PollObstructionSwitch(obstructionChan)
+	go driver.PollFloorSensor(floorSensorChan)
+	go driver.PollObstructionSwitch(obstructionChan)
+	go driver.PollStopButton(stopButtonChan)
 
-	elevator := elevator_control.InitElevator(floorSensorChan)
-	elevator_control.UpdateInfoElev(elevator)
-	elevator_control.SetElevAvailability(true)
+	elevator := elevator_control.InitElevator(driver, numFloors, floorSensorChan)
+	shared.UpdateInfoElev(elevator)
+	shared.SetElevAvailability(true)
 
 	// Initialize timers
-	doorOpenTimer := time.NewTimer(0)
+	doorOpenTimer := clk.NewTimer(0)
 	elevator_control.KillTimer(doorOpenTimer)
-	movementTimer := time.NewTimer(0)
+	movementTimer := clk.NewTimer(0)
 	elevator_control.KillTimer(movementTimer)
+	obstructionTimer := clk.NewTimer(0)
+	elevator_control.KillTimer(obstructionTimer)
+
+	motorFault := false
+	obstructed := false   // obstruction-bryteren er på
+	outOfService := false // døren har vært blokkert lenger enn obstructionTimeout
 
 	for {
 		select {
+		case <-config.Done:
+			return
+
 		case elevator.Orders = <-reqChan:
-			if elevator.State != datatypes.Idle {
+			if elevator.State != datatypes.Idle || elevator.StopActive {
 				break
 			}
 			elevator.Direction, elevator.State = requests.ChooseNewDirAndBeh(elevator)
 			switch elevator.State {
 			case datatypes.DoorOpen:
-				elevio.SetDoorOpenLamp(true)
+				driver.SetDoorOpenLamp(true)
+				doorOpenCycles.Inc()
 				elevator_control.RestartTimer(doorOpenTimer, DOOR_OPEN_DURATION)
 			case datatypes.Moving:
 				elevator_control.RestartTimer(movementTimer, MOVEMENT_TIMEOUT)
-				elevio.SetMotorDirection(elevator_control.DirConv(elevator.Direction))
+				driver.SetMotorDirection(elevator_control.DirConv(elevator.Direction))
 			}
-			elevator_control.UpdateInfoElev(elevator)
+			shared.UpdateInfoElev(elevator)
 
 		case elevator.CurrentFloor = <-floorSensorChan:
+			if motorFault {
+				// etasjesensoren virker igjen, heisen kan ta hall requests på nytt
+				log.Info("Motor fault cleared", "floor", elevator.CurrentFloor)
+				motorFault = false
+				shared.SetMotorFault(false)
+				shared.SetElevAvailability(!elevator.StopActive && !outOfService)
+			}
 			if elevator.State != datatypes.Moving {
 				break
 			}
 			elevator_control.RestartTimer(movementTimer, MOVEMENT_TIMEOUT)
-			elevator_control.SetElevAvailability(true)
-			elevio.SetFloorIndicator(elevator.CurrentFloor)
+			shared.SetElevAvailability(!elevator.StopActive && !outOfService)
+			driver.SetFloorIndicator(elevator.CurrentFloor)
 
 			if requests.ShouldStop(elevator) {
 				elevator_control.KillTimer(movementTimer)
-				elevio.SetMotorDirection(elevio.MotorDirection(datatypes.DIR_STOP))
+				driver.SetMotorDirection(elevio.MotorDirection(datatypes.DIR_STOP))
 
 				// Clear requests at this floor
 				if requests.CanClearHallUp(elevator) {
@@ -73,20 +116,94 @@ func RunElevFSM(reqChan <-chan [datatypes.N_FLOORS][datatypes.N_BUTTONS]bool,
 					completedReqChan <- datatypes.ButtonEvent{Floor: elevator.CurrentFloor, Button: datatypes.BT_CAB}
 				}
 
-				elevio.SetDoorOpenLamp(true)
+				driver.SetDoorOpenLamp(true)
+				doorOpenCycles.Inc()
 				elevator.State = datatypes.DoorOpen
 				elevator_control.RestartTimer(doorOpenTimer, DOOR_OPEN_DURATION)
 			}
+		case isStopPressed := <-stopButtonChan:
+			if isStopPressed {
+				log.Warn("Stop button pressed", "floor", elevator.CurrentFloor, "atFloor", driver.GetFloor() != -1)
+				// nødstopp: stopper motoren umiddelbart, åpner døren dersom heisen står i en etasje
+				elevator.StopActive = true
+				driver.SetMotorDirection(elevio.MD_Stop)
+				driver.SetStopLamp(true)
+				elevator_control.KillTimer(movementTimer)
+				elevator_control.KillTimer(doorOpenTimer)
+				elevator_control.KillTimer(obstructionTimer)
+				shared.SetElevAvailability(false)
+
+				if driver.GetFloor() != -1 {
+					if elevator.State != datatypes.DoorOpen {
+						doorOpenCycles.Inc()
+					}
+					driver.SetDoorOpenLamp(true)
+					elevator.State = datatypes.DoorOpen
+				} else if elevator.State == datatypes.DoorOpen {
+					elevator.State = datatypes.Idle
+				}
+				shared.UpdateInfoElev(elevator)
+				break
+			}
+			if !elevator.StopActive {
+				break
+			}
+			log.Info("Stop button released", "floor", elevator.CurrentFloor)
+			elevator.StopActive = false
+			driver.SetStopLamp(false)
+			shared.SetElevAvailability(!motorFault && !outOfService)
+
+			if elevator.State == datatypes.DoorOpen {
+				// døren lukkes som vanlig, og doorOpenTimer velger ny retning
+				elevator_control.RestartTimer(doorOpenTimer, DOOR_OPEN_DURATION)
+				break
+			}
+
+			// stoppet mellom etasjer
+			prevDirection := elevator.Direction
+			elevator.Direction, elevator.State = requests.ChooseNewDirAndBeh(elevator)
+			if elevator.State == datatypes.DoorOpen {
+				// bestillingen er i forrige etasje, kjører tilbake dit i stedet for å åpne døren mellom etasjer
+				elevator.Direction, elevator.State = oppositeDir(prevDirection), datatypes.Moving
+			}
+			if elevator.State == datatypes.Moving {
+				elevator_control.RestartTimer(movementTimer, MOVEMENT_TIMEOUT)
+				driver.SetMotorDirection(elevator_control.DirConv(elevator.Direction))
+			}
+			shared.UpdateInfoElev(elevator)
+
 		case isObstructed := <-obstructionChan:
+			log.Info("Obstruction switch changed", "active", isObstructed, "floor", elevator.CurrentFloor)
 			if isObstructed {
-				elevator_control.SetElevAvailability(false) // fordi obstructed
+				obstructionEvents.Inc()
+			}
+			obstructed = isObstructed
+			if !isObstructed && outOfService {
+				log.Info("Obstruction cleared, elevator back in service", "floor", elevator.CurrentFloor)
+				outOfService = false
+				shared.SetObstructed(false)
+				shared.SetElevAvailability(!motorFault && !elevator.StopActive)
+			}
+			// obstruction har bare betydning når døren er åpen. Ellers huskes bryteren til døren åpnes
+			if elevator.State != datatypes.DoorOpen || elevator.StopActive {
+				break
+			}
+			if isObstructed {
+				// døren holdes åpen så lenge bryteren er på
 				elevator_control.KillTimer(doorOpenTimer)
+				elevator_control.RestartTimer(obstructionTimer, obstructionTimeout)
 			} else {
-				elevator_control.SetElevAvailability(true)
+				elevator_control.KillTimer(obstructionTimer)
 				elevator_control.RestartTimer(doorOpenTimer, DOOR_OPEN_DURATION)
 			}
-		case <-doorOpenTimer.C:
-			if elevator.State != datatypes.DoorOpen {
+		case <-doorOpenTimer.C():
+			recorder.Timer(journal.TIMER_DOOR)
+			if elevator.State != datatypes.DoorOpen || elevator.StopActive {
+				break
+			}
+			if obstructed {
+				// døren ble åpnet mens bryteren allerede var på, holdes åpen til obstruction er borte
+				elevator_control.RestartTimer(obstructionTimer, obstructionTimeout)
 				break
 			}
 		
@@ -100,7 +217,7 @@ func RunElevFSM(reqChan <-chan [datatypes.N_FLOORS][datatypes.N_BUTTONS]bool,
 			}
 		
 			if cleared {
-				elevio.SetDoorOpenLamp(false)
+				driver.SetDoorOpenLamp(false)
 			}
 		
 			elevator.Direction, elevator.State = requests.ChooseNewDirAndBeh(elevator)
@@ -109,18 +226,49 @@ func RunElevFSM(reqChan <-chan [datatypes.N_FLOORS][datatypes.N_BUTTONS]bool,
 			case datatypes.DoorOpen:
 				elevator_control.RestartTimer(doorOpenTimer, DOOR_OPEN_DURATION)
 			case datatypes.Idle:
-				elevio.SetDoorOpenLamp(false)
+				driver.SetDoorOpenLamp(false)
 			case datatypes.Moving:
-				elevio.SetDoorOpenLamp(false)
+				driver.SetDoorOpenLamp(false)
 				elevator_control.RestartTimer(movementTimer, MOVEMENT_TIMEOUT)
-				elevio.SetMotorDirection(elevator_control.DirConv(elevator.Direction))
+				driver.SetMotorDirection(elevator_control.DirConv(elevator.Direction))
 			}
 		
-			elevator_control.UpdateInfoElev(elevator)
+			shared.UpdateInfoElev(elevator)
 		
 
-		case <-movementTimer.C:
-			elevator_control.SetElevAvailability(false)
+		case <-obstructionTimer.C():
+			recorder.Timer(journal.TIMER_OBSTRUCTION)
+			if !obstructed || outOfService || elevator.State != datatypes.DoorOpen || elevator.StopActive {
+				break
+			}
+			// døren har vært blokkert for lenge. Heisen tas ut av drift, og peers tar over hall requests.
+			// Døren holdes fortsatt åpen, og heisen er i drift igjen når obstruction er borte
+			log.Error("Door obstructed too long, taking elevator out of service", "timeout_s", obstructionTimeout, "floor", elevator.CurrentFloor)
+			obstructionTimeouts.Inc()
+			outOfService = true
+			shared.SetObstructed(true)
+			shared.SetElevAvailability(false)
+
+		case <-movementTimer.C():
+			recorder.Timer(journal.TIMER_MOVEMENT)
+			// motorfeil: ingen etasje nådd innen MOVEMENT_TIMEOUT. Motoren står fortsatt på, slik at heisen
+			// kommer tilbake av seg selv dersom feilen forsvinner. Peers tar over hall requests
+			log.Error("Motor fault: no floor reached in time", "timeout_s", MOVEMENT_TIMEOUT, "floor", elevator.CurrentFloor, "direction", elevator.Direction)
+			movementTimerExpiries.Inc()
+			motorFault = true
+			shared.SetMotorFault(true)
+			shared.SetElevAvailability(false)
 		}
 	}
 }
+
+// retningen tilbake til etasjen heisen kom fra
+func oppositeDir(dir datatypes.Direction) datatypes.Direction {
+	switch dir {
+	case datatypes.DIR_UP:
+		return datatypes.DIR_DOWN
+	case datatypes.DIR_DOWN:
+		return datatypes.DIR_UP
+	}
+	return datatypes.DIR_DOWN
+}
@@ -0,0 +1,303 @@
+package fsm
+
+import (
+	"project/clock"
+	"project/datatypes"
+	"project/elevator_control"
+	"project/elevio"
+	"testing"
+	"time"
+)
+
+const NUM_FLOORS = 4
+const WAIT_TIMEOUT = 2 * time.Second
+
+type testElevator struct {
+	driver    *elevio.FakeDriver
+	clk       *clock.Virtual
+	shared    *elevator_control.Shared
+	orders    chan [][datatypes.N_BUTTONS]bool
+	completed chan datatypes.ButtonEvent
+	done      chan struct{}
+}
+
+// starter RunElevFSM på en FakeDriver i etasje floor, med en virtuell klokke for timerne
+func startElevator(t *testing.T, floor int) *testElevator {
+	e := &testElevator{
+		driver:    elevio.NewFakeDriver(NUM_FLOORS),
+		clk:       clock.NewFake(),
+		shared:    elevator_control.NewShared(),
+		orders:    make(chan [][datatypes.N_BUTTONS]bool),
+		completed: make(chan datatypes.ButtonEvent, NUM_FLOORS*datatypes.N_BUTTONS),
+		done:      make(chan struct{}),
+	}
+	t.Cleanup(func() { close(e.done) })
+	e.driver.SetFloor(floor)
+
+	config := FSMConfig{NumFloors: NUM_FLOORS, Clock: e.clk, Shared: e.shared, Done: e.done}
+	go RunElevFSM(e.driver, config, e.orders, e.completed)
+	waitFor(t, "elevator initialised", func() bool { return e.driver.FloorIndicator() == floor })
+	return e
+}
+
+func (e *testElevator) sendOrders(t *testing.T, orders [][datatypes.N_BUTTONS]bool) {
+	select {
+	case e.orders <- orders:
+	case <-time.After(WAIT_TIMEOUT):
+		t.Fatal("fsm did not take the orders")
+	}
+}
+
+func (e *testElevator) state() datatypes.ElevBehaviour {
+	return e.shared.GetElevator().State
+}
+
+func (e *testElevator) info() datatypes.ElevatorInfo {
+	return e.shared.GetInfoElev()
+}
+
+// venter til timeren som går ut først har frist d fram i tid, f.eks. til fsm har byttet ut doorOpenTimer
+func (e *testElevator) waitForDeadline(t *testing.T, what string, d time.Duration) {
+	t.Helper()
+	waitFor(t, what, func() bool {
+		next, ok := e.clk.NextDeadline()
+		return ok && next.Sub(e.clk.Now()) == d
+	})
+}
+
+// venter i vanlig tid, siden fsm leser sensorene i egne goroutines
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(WAIT_TIMEOUT)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFSMArrivalDoorOpenDoorClose(t *testing.T) {
+	e := startElevator(t, 0)
+
+	orders := datatypes.NewOrders(NUM_FLOORS)
+	orders[2][datatypes.BT_CAB] = true
+	e.sendOrders(t, orders)
+	waitFor(t, "motor up", func() bool { return e.driver.MotorDirection() == elevio.MD_Up })
+
+	// passerer etasje 1 uten bestilling
+	e.driver.SetFloor(1)
+	waitFor(t, "floor indicator 1", func() bool { return e.driver.FloorIndicator() == 1 })
+	if e.driver.MotorDirection() != elevio.MD_Up || e.driver.DoorOpenLamp() {
+		t.Fatal("elevator stopped at floor 1 without an order")
+	}
+
+	// ankommer etasje 2: stopper, åpner døren og melder cab-bestillingen som utført
+	e.driver.SetFloor(2)
+	waitFor(t, "door open at floor 2", e.driver.DoorOpenLamp)
+	if e.driver.MotorDirection() != elevio.MD_Stop {
+		t.Fatal("motor still running with the door open")
+	}
+	select {
+	case completed := <-e.completed:
+		if completed.Floor != 2 || completed.Button != datatypes.BT_CAB {
+			t.Fatal("unexpected completed request", completed)
+		}
+	case <-time.After(WAIT_TIMEOUT):
+		t.Fatal("cab request at floor 2 was not completed")
+	}
+
+	// døren lukkes når doorOpenTimer går ut
+	if !e.clk.BlockUntil(1, WAIT_TIMEOUT) {
+		t.Fatal("door timer was not started")
+	}
+	e.clk.Advance(DOOR_OPEN_DURATION * time.Second)
+	waitFor(t, "door closed", func() bool { return e.state() == datatypes.Idle })
+	if e.driver.DoorOpenLamp() {
+		t.Fatal("door open lamp is on after the door closed")
+	}
+	if e.driver.MotorDirection() != elevio.MD_Stop {
+		t.Fatal("motor running with no orders")
+	}
+}
+
+// døren holdes åpen i nøyaktig DOOR_OPEN_DURATION sekunder på den virtuelle klokken
+func TestFSMDoorTimeout(t *testing.T) {
+	e := startElevator(t, 1)
+
+	orders := datatypes.NewOrders(NUM_FLOORS)
+	orders[1][datatypes.BT_HallUP] = true
+	e.sendOrders(t, orders)
+	waitFor(t, "door open at floor 1", e.driver.DoorOpenLamp)
+	if !e.clk.BlockUntil(1, WAIT_TIMEOUT) {
+		t.Fatal("door timer was not started")
+	}
+
+	e.clk.Advance(DOOR_OPEN_DURATION*time.Second - time.Millisecond)
+	time.Sleep(20 * time.Millisecond) // gir fsm tid til å reagere dersom timeren feilaktig gikk ut
+	if !e.driver.DoorOpenLamp() || e.state() != datatypes.DoorOpen {
+		t.Fatal("door closed before DOOR_OPEN_DURATION")
+	}
+
+	e.clk.Advance(time.Millisecond)
+	waitFor(t, "door closed", func() bool { return e.state() == datatypes.Idle && !e.driver.DoorOpenLamp() })
+	select {
+	case completed := <-e.completed:
+		if completed.Floor != 1 || completed.Button != datatypes.BT_HallUP {
+			t.Fatal("unexpected completed request", completed)
+		}
+	default:
+		t.Fatal("hall request at floor 1 was not completed when the door closed")
+	}
+}
+
+// movementTimer går ut uten at noen etasje er nådd: motorfeil, og heisen er ikke tilgjengelig før
+// den kommer fram til en etasje
+func TestFSMMotorFaultAndRecovery(t *testing.T) {
+	e := startElevator(t, 0)
+
+	orders := datatypes.NewOrders(NUM_FLOORS)
+	orders[3][datatypes.BT_CAB] = true
+	e.sendOrders(t, orders)
+	waitFor(t, "motor up", func() bool { return e.driver.MotorDirection() == elevio.MD_Up })
+	e.waitForDeadline(t, "movement timer", MOVEMENT_TIMEOUT*time.Second)
+
+	e.clk.Advance(MOVEMENT_TIMEOUT * time.Second)
+	waitFor(t, "motor fault", func() bool { return e.info().MotorFault })
+	if e.info().Available {
+		t.Fatal("elevator available with a motor fault")
+	}
+	if e.driver.MotorDirection() != elevio.MD_Up {
+		t.Fatal("motor was stopped by the fault, the elevator can not come back by itself")
+	}
+
+	e.driver.SetFloor(1)
+	waitFor(t, "motor fault cleared", func() bool { return !e.info().MotorFault && e.driver.FloorIndicator() == 1 })
+	if !e.info().Available {
+		t.Fatal("elevator not available after reaching a floor")
+	}
+	if e.driver.MotorDirection() != elevio.MD_Up {
+		t.Fatal("elevator stopped at floor 1 without an order")
+	}
+}
+
+// heisen når en etasje etter motorfeilen mens stoppknappen er inne, og skal ikke meldes tilgjengelig
+func TestFSMMotorFaultRecoveryDuringStop(t *testing.T) {
+	e := startElevator(t, 0)
+
+	orders := datatypes.NewOrders(NUM_FLOORS)
+	orders[3][datatypes.BT_CAB] = true
+	e.sendOrders(t, orders)
+	waitFor(t, "motor up", func() bool { return e.driver.MotorDirection() == elevio.MD_Up })
+	e.waitForDeadline(t, "movement timer", MOVEMENT_TIMEOUT*time.Second)
+	e.driver.SetFloor(-1)
+	e.clk.Advance(MOVEMENT_TIMEOUT * time.Second)
+	waitFor(t, "motor fault", func() bool { return e.info().MotorFault })
+
+	e.driver.SetStop(true)
+	waitFor(t, "stop lamp", e.driver.StopLamp)
+	e.driver.SetFloor(1)
+	waitFor(t, "floor 1 reached", func() bool { return !e.info().MotorFault && e.driver.FloorIndicator() == 1 })
+	if e.info().Available {
+		t.Fatal("elevator reported available with the stop button pressed")
+	}
+}
+
+// stopp mellom etasjer: motoren stopper uten at døren åpnes, og heisen kjører videre når knappen slippes
+func TestFSMStopBetweenFloors(t *testing.T) {
+	e := startElevator(t, 0)
+
+	orders := datatypes.NewOrders(NUM_FLOORS)
+	orders[2][datatypes.BT_CAB] = true
+	e.sendOrders(t, orders)
+	waitFor(t, "motor up", func() bool { return e.driver.MotorDirection() == elevio.MD_Up })
+	e.driver.SetFloor(-1)
+
+	e.driver.SetStop(true)
+	waitFor(t, "stop lamp", e.driver.StopLamp)
+	if e.driver.MotorDirection() != elevio.MD_Stop {
+		t.Fatal("motor still running with the stop button pressed")
+	}
+	if e.driver.DoorOpenLamp() {
+		t.Fatal("door opened between floors")
+	}
+	if e.info().Available {
+		t.Fatal("elevator available with the stop button pressed")
+	}
+
+	e.driver.SetStop(false)
+	waitFor(t, "stop released", func() bool { return !e.driver.StopLamp() })
+	waitFor(t, "motor up again", func() bool { return e.driver.MotorDirection() == elevio.MD_Up })
+	if !e.info().Available {
+		t.Fatal("elevator not available after the stop button was released")
+	}
+}
+
+// stopp i en etasje åpner døren, og den lukkes som vanlig først etter at knappen er sluppet
+func TestFSMStopAtFloor(t *testing.T) {
+	e := startElevator(t, 1)
+
+	e.driver.SetStop(true)
+	waitFor(t, "door open", func() bool { return e.driver.StopLamp() && e.driver.DoorOpenLamp() })
+	if e.state() != datatypes.DoorOpen || e.info().Available {
+		t.Fatal("elevator not stopped with the door open")
+	}
+	if e.clk.Waiters() != 0 {
+		t.Fatal("a timer is running with the stop button pressed")
+	}
+
+	e.driver.SetStop(false)
+	waitFor(t, "stop released", func() bool { return !e.driver.StopLamp() })
+	e.waitForDeadline(t, "door timer", DOOR_OPEN_DURATION*time.Second)
+	e.clk.Advance(DOOR_OPEN_DURATION * time.Second)
+	waitFor(t, "door closed", func() bool { return e.state() == datatypes.Idle && !e.driver.DoorOpenLamp() })
+	if !e.info().Available {
+		t.Fatal("elevator not available after the stop button was released")
+	}
+}
+
+// døren holdes åpen mens den er blokkert, og etter OBSTRUCTION_TIMEOUT tas heisen ut av drift til
+// obstruction er borte
+func TestFSMObstructionTimeout(t *testing.T) {
+	e := startElevator(t, 1)
+
+	orders := datatypes.NewOrders(NUM_FLOORS)
+	orders[1][datatypes.BT_HallUP] = true
+	e.sendOrders(t, orders)
+	waitFor(t, "door open at floor 1", e.driver.DoorOpenLamp)
+	e.waitForDeadline(t, "door timer", DOOR_OPEN_DURATION*time.Second)
+
+	e.driver.SetObstruction(true)
+	e.waitForDeadline(t, "obstruction timer", OBSTRUCTION_TIMEOUT*time.Second)
+	e.clk.Advance(DOOR_OPEN_DURATION * time.Second)
+	time.Sleep(20 * time.Millisecond) // gir fsm tid til å reagere dersom døren feilaktig lukkes
+	if !e.driver.DoorOpenLamp() || e.state() != datatypes.DoorOpen {
+		t.Fatal("door closed while obstructed")
+	}
+	if !e.info().Available {
+		t.Fatal("elevator out of service before OBSTRUCTION_TIMEOUT")
+	}
+
+	e.clk.Advance((OBSTRUCTION_TIMEOUT - DOOR_OPEN_DURATION) * time.Second)
+	waitFor(t, "out of service", func() bool { return e.info().Obstructed })
+	if e.info().Available {
+		t.Fatal("elevator available while out of service")
+	}
+	if !e.driver.DoorOpenLamp() {
+		t.Fatal("door closed when the elevator was taken out of service")
+	}
+
+	e.driver.SetObstruction(false)
+	waitFor(t, "back in service", func() bool { return !e.info().Obstructed && e.info().Available })
+	e.waitForDeadline(t, "door timer", DOOR_OPEN_DURATION*time.Second)
+	e.clk.Advance(DOOR_OPEN_DURATION * time.Second)
+	waitFor(t, "door closed", func() bool { return e.state() == datatypes.Idle && !e.driver.DoorOpenLamp() })
+	select {
+	case completed := <-e.completed:
+		if completed.Floor != 1 || completed.Button != datatypes.BT_HallUP {
+			t.Fatal("unexpected completed request", completed)
+		}
+	default:
+		t.Fatal("hall request at floor 1 was not completed when the door closed")
+	}
+}
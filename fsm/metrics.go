@@ -0,0 +1,10 @@
+package fsm
+
+import "project/metrics"
+
+var (
+	doorOpenCycles        = metrics.NewCounter("elevator_door_open_cycles_total", "Times the door has been opened.")
+	movementTimerExpiries = metrics.NewCounter("elevator_movement_timer_expiries_total", "Times no floor was reached within MOVEMENT_TIMEOUT.")
+	obstructionEvents     = metrics.NewCounter("elevator_obstruction_events_total", "Times the obstruction switch has been activated.")
+	obstructionTimeouts   = metrics.NewCounter("elevator_obstruction_timeouts_total", "Times the car was taken out of service after being obstructed for longer than the obstruction timeout.")
+)
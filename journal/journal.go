@@ -0,0 +1,231 @@
+package journal
+
+// Journal over alt som kommer inn til en node: knappetrykk, etasjesensor, obstruction og stoppknapp,
+// mottatte NetworkMsg, peer-oppdateringer og timere som går ut. Brukes av replay for å kjøre noden
+// på nytt med nøyaktig samme input.
+//
+// Filen er JSON med én linje per hendelse. Første linje er en Header med oppsettet til noden.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"project/clock"
+	"project/datatypes"
+	"project/elevio"
+	"project/network/peers"
+	"sync"
+	"time"
+)
+
+const JOURNAL_VERSION = 1
+
+const (
+	EVENT_BUTTON      = "button"
+	EVENT_FLOOR       = "floor"       // verdi fra etasjesensoren
+	EVENT_GET_FLOOR   = "get_floor"   // svar på driver.GetFloor() fra fsm
+	EVENT_OBSTRUCTION = "obstruction" // obstruction-bryteren endret seg
+	EVENT_STOP        = "stop"        // stoppknappen endret seg
+	EVENT_NETWORK_MSG = "network_msg"
+	EVENT_PEER_UPDATE = "peer_update"
+	EVENT_CAB_RESTORE = "cab_restore" // cab requests hentet fra cab-journalen ved oppstart
+	EVENT_TIMER       = "timer"
+)
+
+// navn på timere i EVENT_TIMER
+const (
+	TIMER_DOOR        = "door"
+	TIMER_MOVEMENT    = "movement"
+	TIMER_OBSTRUCTION = "obstruction"
+	TIMER_CAB_RESTORE = "cab_restore"
+	TIMER_BROADCAST   = "broadcast"
+	TIMER_ASSIGN      = "assign"
+)
+
+type Header struct {
+	Version            int       `json:"version"`
+	LocalID            string    `json:"localID"`
+	NumFloors          int       `json:"numFloors"`
+	Assigner           string    `json:"assigner"`
+	PreferBinaryWire   bool      `json:"preferBinaryWire"`
+	ObstructionTimeout int       `json:"obstructionTimeout,omitempty"` // sekunder, 0 i journaler fra før innstillingen fantes
+	OfflineHallCalls   bool      `json:"offlineHallCalls,omitempty"`
+	Start              time.Time `json:"start"`
+}
+
+// Event har bare feltene som hører til Type satt
+type Event struct {
+	Time        time.Duration           `json:"t"` // tid siden Header.Start
+	Type        string                  `json:"type"`
+	Button      *elevio.ButtonEvent     `json:"button,omitempty"`
+	Floor       int                     `json:"floor,omitempty"`
+	Value       bool                    `json:"value,omitempty"`
+	Msg         *datatypes.NetworkMsg   `json:"msg,omitempty"`
+	PeerUpdate  *peers.PeerUpdate       `json:"peerUpdate,omitempty"`
+	CabRequests []datatypes.RequestType `json:"cabRequests,omitempty"`
+	Timer       string                  `json:"timer,omitempty"`
+}
+
+// Recorder skriver hendelser til journalen. Kan brukes fra flere goroutines, og en nil Recorder gjør ingenting.
+type Recorder struct {
+	mtx    sync.Mutex
+	clk    clock.Clock
+	start  time.Time
+	out    *bufio.Writer
+	file   *os.File
+	err    error
+	closed bool
+}
+
+// Create lager en ny journal i path og skriver header. Start settes fra klokken
+func Create(path string, header Header, clk clock.Clock) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewRecorder(file, header, clk)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	r.file = file
+	return r, nil
+}
+
+// NewRecorder skriver journalen til out, f.eks. en buffer ved replay
+func NewRecorder(out io.Writer, header Header, clk clock.Clock) (*Recorder, error) {
+	clk = clock.OrReal(clk)
+	header.Version = JOURNAL_VERSION
+	header.Start = clk.Now()
+	r := &Recorder{clk: clk, start: header.Start, out: bufio.NewWriter(out)}
+	if err := r.writeLine(header); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) Button(btn elevio.ButtonEvent) {
+	r.record(Event{Type: EVENT_BUTTON, Button: &btn})
+}
+
+func (r *Recorder) Floor(floor int) {
+	r.record(Event{Type: EVENT_FLOOR, Floor: floor})
+}
+
+func (r *Recorder) GetFloor(floor int) {
+	r.record(Event{Type: EVENT_GET_FLOOR, Floor: floor})
+}
+
+func (r *Recorder) Obstruction(value bool) {
+	r.record(Event{Type: EVENT_OBSTRUCTION, Value: value})
+}
+
+func (r *Recorder) Stop(value bool) {
+	r.record(Event{Type: EVENT_STOP, Value: value})
+}
+
+func (r *Recorder) NetworkMsg(msg datatypes.NetworkMsg) {
+	r.record(Event{Type: EVENT_NETWORK_MSG, Msg: &msg})
+}
+
+func (r *Recorder) PeerUpdate(update peers.PeerUpdate) {
+	r.record(Event{Type: EVENT_PEER_UPDATE, PeerUpdate: &update})
+}
+
+func (r *Recorder) CabRestore(cabRequests []datatypes.RequestType) {
+	r.record(Event{Type: EVENT_CAB_RESTORE, CabRequests: datatypes.CopyCabRequests(cabRequests)})
+}
+
+func (r *Recorder) Timer(name string) {
+	r.record(Event{Type: EVENT_TIMER, Timer: name})
+}
+
+func (r *Recorder) record(event Event) {
+	if r == nil {
+		return
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.closed || r.err != nil {
+		return
+	}
+	event.Time = r.clk.Now().Sub(r.start)
+	if err := r.writeLine(event); err != nil {
+		// journalen er bare til feilsøking, noden fortsetter uten
+		r.err = err
+		log.Error("Event journal write failed, recording stopped", "err", err)
+	}
+}
+
+// writeLine skriver og flusher, slik at journalen er komplett fram til en eventuell krasj
+func (r *Recorder) writeLine(v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := r.out.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return r.out.Flush()
+}
+
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	flushErr := r.out.Flush()
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return err
+		}
+	}
+	return flushErr
+}
+
+// Read leser en journal. En ufullstendig siste linje, f.eks. etter en krasj, blir hoppet over
+func Read(in io.Reader) (Header, []Event, error) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	header := Header{}
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return header, nil, err
+		}
+		return header, nil, fmt.Errorf("empty journal")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return header, nil, fmt.Errorf("invalid journal header: %v", err)
+	}
+	if header.Version != JOURNAL_VERSION {
+		return header, nil, fmt.Errorf("unsupported journal version %d", header.Version)
+	}
+
+	events := []Event{}
+	for line := 2; scanner.Scan(); line++ {
+		event := Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			log.Warn("Ignoring invalid journal line", "line", line, "err", err)
+			continue
+		}
+		events = append(events, event)
+	}
+	return header, events, scanner.Err()
+}
+
+func ReadFile(path string) (Header, []Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Header{}, nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
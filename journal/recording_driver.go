@@ -0,0 +1,52 @@
+package journal
+
+import (
+	"project/elevio"
+	"project/logging"
+)
+
+var log = logging.New("journal")
+
+// RecordingDriver skriver input fra etasjesensor, stoppknapp og obstruction til journalen.
+// Knappetrykk skrives av RequestControlLoop, siden de også kan komme fra HTTP API-et
+type RecordingDriver struct {
+	elevio.ElevatorDriver
+	recorder *Recorder
+}
+
+func NewRecordingDriver(driver elevio.ElevatorDriver, recorder *Recorder) *RecordingDriver {
+	return &RecordingDriver{ElevatorDriver: driver, recorder: recorder}
+}
+
+func (d *RecordingDriver) PollFloorSensor(receiver chan<- int) {
+	floors := make(chan int)
+	go d.ElevatorDriver.PollFloorSensor(floors)
+	for floor := range floors {
+		d.recorder.Floor(floor)
+		receiver <- floor
+	}
+}
+
+func (d *RecordingDriver) PollStopButton(receiver chan<- bool) {
+	values := make(chan bool)
+	go d.ElevatorDriver.PollStopButton(values)
+	for value := range values {
+		d.recorder.Stop(value)
+		receiver <- value
+	}
+}
+
+func (d *RecordingDriver) PollObstructionSwitch(receiver chan<- bool) {
+	values := make(chan bool)
+	go d.ElevatorDriver.PollObstructionSwitch(values)
+	for value := range values {
+		d.recorder.Obstruction(value)
+		receiver <- value
+	}
+}
+
+func (d *RecordingDriver) GetFloor() int {
+	floor := d.ElevatorDriver.GetFloor()
+	d.recorder.GetFloor(floor)
+	return floor
+}
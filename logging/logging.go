@@ -0,0 +1,255 @@
+package logging
+
+// Logging med nivåer og én logger per pakke. Linjene skrives som tekst eller JSON til stderr.
+// Nivået settes for alle pakker, og kan overstyres per pakke, f.eks. "info,fsm=debug".
+//
+//	var log = logging.New("fsm")
+//	log.Info("Motor fault cleared", "floor", 2)
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	DEBUG Level = iota
+	INFO
+	WARN
+	ERROR
+)
+
+const (
+	FORMAT_TEXT = "text"
+	FORMAT_JSON = "json"
+)
+
+func (l Level) String() string {
+	switch l {
+	case DEBUG:
+		return "debug"
+	case INFO:
+		return "info"
+	case WARN:
+		return "warn"
+	case ERROR:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// levels skrives som tekst i JSON
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return DEBUG, nil
+	case "info":
+		return INFO, nil
+	case "warn", "warning":
+		return WARN, nil
+	case "error":
+		return ERROR, nil
+	}
+	return INFO, fmt.Errorf("unknown log level %q (valid: debug, info, warn, error)", s)
+}
+
+var (
+	_mtx           sync.RWMutex
+	_level                   = INFO
+	_packageLevels           = map[string]Level{}
+	_format                  = FORMAT_TEXT
+	_out           io.Writer = os.Stderr
+)
+
+func SetLevel(level Level) {
+	_mtx.Lock()
+	defer _mtx.Unlock()
+	_level = level
+}
+
+// SetPackageLevel overstyrer nivået for én pakke
+func SetPackageLevel(pkg string, level Level) {
+	_mtx.Lock()
+	defer _mtx.Unlock()
+	_packageLevels[pkg] = level
+}
+
+// ClearPackageLevel gjør at pakken bruker det felles nivået igjen
+func ClearPackageLevel(pkg string) {
+	_mtx.Lock()
+	defer _mtx.Unlock()
+	delete(_packageLevels, pkg)
+}
+
+// ApplyLevelSpec tar inn f.eks. "warn,requests=debug,fsm=info". Et nivå uten pakke setter det felles nivået
+func ApplyLevelSpec(spec string) error {
+	level := GetLevel()
+	packageLevels := map[string]Level{}
+	for _, part := range strings.Split(spec, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		pkg, levelString := "", part
+		if i := strings.Index(part, "="); i >= 0 {
+			pkg, levelString = strings.TrimSpace(part[:i]), part[i+1:]
+		}
+		parsed, err := ParseLevel(levelString)
+		if err != nil {
+			return err
+		}
+		if pkg == "" {
+			level = parsed
+		} else {
+			packageLevels[pkg] = parsed
+		}
+	}
+
+	_mtx.Lock()
+	defer _mtx.Unlock()
+	_level = level
+	for pkg, parsed := range packageLevels {
+		_packageLevels[pkg] = parsed
+	}
+	return nil
+}
+
+func GetLevel() Level {
+	_mtx.RLock()
+	defer _mtx.RUnlock()
+	return _level
+}
+
+// GetPackageLevels returnerer kopi av nivåene som er overstyrt per pakke
+func GetPackageLevels() map[string]Level {
+	_mtx.RLock()
+	defer _mtx.RUnlock()
+	levels := make(map[string]Level, len(_packageLevels))
+	for pkg, level := range _packageLevels {
+		levels[pkg] = level
+	}
+	return levels
+}
+
+func SetFormat(format string) error {
+	if format != FORMAT_TEXT && format != FORMAT_JSON {
+		return fmt.Errorf("unknown log format %q (valid: %s, %s)", format, FORMAT_TEXT, FORMAT_JSON)
+	}
+	_mtx.Lock()
+	defer _mtx.Unlock()
+	_format = format
+	return nil
+}
+
+func SetOutput(out io.Writer) {
+	_mtx.Lock()
+	defer _mtx.Unlock()
+	_out = out
+}
+
+type Logger struct {
+	pkg string
+}
+
+func New(pkg string) *Logger {
+	return &Logger{pkg: pkg}
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	_mtx.RLock()
+	defer _mtx.RUnlock()
+	if pkgLevel, ok := _packageLevels[l.pkg]; ok {
+		return level >= pkgLevel
+	}
+	return level >= _level
+}
+
+// keyvals er par av nøkkel og verdi: log.Info("Peer joined", "id", id, "peers", peerList)
+func (l *Logger) Debug(msg string, keyvals ...interface{}) { l.log(DEBUG, msg, keyvals) }
+func (l *Logger) Info(msg string, keyvals ...interface{})  { l.log(INFO, msg, keyvals) }
+func (l *Logger) Warn(msg string, keyvals ...interface{})  { l.log(WARN, msg, keyvals) }
+func (l *Logger) Error(msg string, keyvals ...interface{}) { l.log(ERROR, msg, keyvals) }
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, "(missing)")
+	}
+	now := time.Now()
+
+	_mtx.Lock()
+	defer _mtx.Unlock()
+	if _format == FORMAT_JSON {
+		writeJSON(_out, now, level, l.pkg, msg, keyvals)
+	} else {
+		writeText(_out, now, level, l.pkg, msg, keyvals)
+	}
+}
+
+func writeText(out io.Writer, now time.Time, level Level, pkg, msg string, keyvals []interface{}) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-5s %s: %s", now.Format("2006-01-02T15:04:05.000Z07:00"), strings.ToUpper(level.String()), pkg, msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		value := fmt.Sprint(valueOf(keyvals[i+1]))
+		if strings.ContainsAny(value, " \"=") || value == "" {
+			value = fmt.Sprintf("%q", value)
+		}
+		fmt.Fprintf(&b, " %v=%s", keyvals[i], value)
+	}
+	b.WriteString("\n")
+	io.WriteString(out, b.String())
+}
+
+func writeJSON(out io.Writer, now time.Time, level Level, pkg, msg string, keyvals []interface{}) {
+	fields := map[string]interface{}{
+		"time":  now.Format(time.RFC3339Nano),
+		"level": level.String(),
+		"pkg":   pkg,
+		"msg":   msg,
+	}
+	for i := 0; i < len(keyvals); i += 2 {
+		fields[jsonKey(fields, keyvals[i])] = valueOf(keyvals[i+1])
+	}
+	line, err := json.Marshal(fields)
+	if err != nil {
+		// en av verdiene kan ikke kodes som JSON, skriver dem som tekst i stedet
+		for key, value := range fields {
+			fields[key] = fmt.Sprint(value)
+		}
+		line, _ = json.Marshal(fields)
+	}
+	out.Write(append(line, '\n'))
+}
+
+// nøkler som time, level, pkg og msg får prefiks i stedet for å overskrive feltene til selve linjen
+func jsonKey(fields map[string]interface{}, key interface{}) string {
+	name := fmt.Sprint(key)
+	for {
+		if _, taken := fields[name]; !taken {
+			return name
+		}
+		name = "_" + name
+	}
+}
+
+// errors blir til {} i JSON, og typer som RequestState blir tall. Skriver teksten i stedet
+func valueOf(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return value
+}
@@ -3,33 +3,158 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
+	"project/api"
 	"project/datatypes"
 	"project/elevio"
 	"project/fsm"
+	"project/journal"
+	"project/logging"
+	"project/network/auth"
+	"project/network/bcast"
+	"project/network/wire"
+	"project/replay"
 	"project/requests"
+	request_handler "project/requests/request_handler"
 )
 
+var log = logging.New("main")
+
 func main() {
 
 	idFlag := flag.String("id", "", "Unique ID for this elevator")
 	portFlag := flag.String("port", "15657", "Simulator port")
+	floorsFlag := flag.Int("floors", datatypes.DEFAULT_N_FLOORS, "Number of floors in the building")
+	cabJournalFlag := flag.String("cabjournal", "", "File for persisting cab requests (default cab_requests_<id>.journal, \"none\" disables)")
+	authKeyFlag := flag.String("authkey", "", "Pre-shared key for authenticating broadcast packets (empty disables)")
+	wireFlag := flag.String("wire", "json", "Encoding of status broadcasts: json, or binary once all peers support it (both are always accepted)")
+	assignerFlag := flag.String("assigner", request_handler.ASSIGNER_TIME_TO_IDLE, "Hall request assignment strategy: cost, nearest or zone")
+	httpFlag := flag.String("http", "", "Address for the HTTP status/control API, dashboard and /metrics, e.g. :8080 (empty disables)")
+	logLevelFlag := flag.String("loglevel", "info", "Log level, optionally per package: e.g. warn or info,requests=debug,fsm=debug")
+	logFormatFlag := flag.String("logformat", logging.FORMAT_TEXT, "Log output format: text or json")
+	eventJournalFlag := flag.String("eventjournal", "", "File for recording every input to the node, for use with -replay (empty disables)")
+	obstructionTimeoutFlag := flag.Int("obstructiontimeout", fsm.OBSTRUCTION_TIMEOUT, "Seconds the door can be obstructed before the elevator is taken out of service and its hall requests reassigned")
+	offlineHallFlag := flag.Bool("offlinehall", false, "Accept and serve hall calls alone while disconnected from the network, and reconcile them by Count on reconnect")
+	replayFlag := flag.String("replay", "", "Replay an event journal on a virtual clock and print the resulting state, instead of running the node")
 	flag.Parse()
 
+	if err := logging.ApplyLevelSpec(*logLevelFlag); err != nil {
+		fmt.Println("Error: -loglevel:", err)
+		return
+	}
+
+	if err := logging.SetFormat(*logFormatFlag); err != nil {
+		fmt.Println("Error: -logformat:", err)
+		return
+	}
+
+	if *replayFlag != "" {
+		result, err := replay.RunFile(*replayFlag, replay.Options{})
+		if err != nil {
+			fmt.Println("Error: replay:", err)
+			os.Exit(1)
+		}
+		result.WriteReport(os.Stdout)
+		if len(result.Divergences) > 0 {
+			os.Exit(2)
+		}
+		return
+	}
+
 	if *idFlag == "" {
 		fmt.Println("Error: -id must be provided")
 		return
 	}
 
+	if *wireFlag != "json" && *wireFlag != "binary" {
+		fmt.Println("Error: -wire must be json or binary")
+		return
+	}
+
+	if *floorsFlag < 2 || *floorsFlag > datatypes.MAX_N_FLOORS {
+		fmt.Println("Error: -floors must be between 2 and", datatypes.MAX_N_FLOORS)
+		return
+	}
+
+	assigner, err := request_handler.NewAssigner(*assignerFlag)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
 	myID := *idFlag
 	port := *portFlag
+	numFloors := *floorsFlag
+
+	cabJournalPath := *cabJournalFlag
+	switch cabJournalPath {
+	case "":
+		cabJournalPath = "cab_requests_" + myID + ".journal"
+	case "none":
+		cabJournalPath = ""
+	}
+
+	// binær koding slås på av RequestControlLoop når alle peers støtter den
+	bcast.RegisterTypeId(datatypes.NetworkMsg{}, datatypes.NETWORK_MSG_TYPE_ID)
+	bcast.RegisterCodec(datatypes.NetworkMsg{}, wire.NetworkMsgCodec{}, false)
+
+	if *authKeyFlag != "" {
+		auth.Configure([]byte(*authKeyFlag), myID)
+	}
 
-	elevio.Init("localhost:"+port, datatypes.N_FLOORS)
+	var driver elevio.ElevatorDriver = elevio.NewTCPDriver("localhost:"+port, numFloors)
 
-	requestsCh := make(chan [datatypes.N_FLOORS][datatypes.N_BUTTONS]bool)
+	var recorder *journal.Recorder
+	if *eventJournalFlag != "" {
+		header := journal.Header{
+			LocalID:            myID,
+			NumFloors:          numFloors,
+			Assigner:           *assignerFlag,
+			PreferBinaryWire:   *wireFlag == "binary",
+			ObstructionTimeout: *obstructionTimeoutFlag,
+			OfflineHallCalls:   *offlineHallFlag,
+		}
+		recorder, err = journal.Create(*eventJournalFlag, header, nil)
+		if err != nil {
+			fmt.Println("Error: -eventjournal:", err)
+			return
+		}
+		driver = journal.NewRecordingDriver(driver, recorder)
+	}
+
+	requestsCh := make(chan [][datatypes.N_BUTTONS]bool)
 	completedRequestCh := make(chan datatypes.ButtonEvent)
 
-	go fsm.RunElevFSM(requestsCh, completedRequestCh)
-	go requests.RequestControlLoop(myID, requestsCh, completedRequestCh)
+	config := requests.RequestConfig{
+		LocalID:          myID,
+		NumFloors:        numFloors,
+		Assigner:         assigner,
+		CabJournalPath:   cabJournalPath,
+		PreferBinaryWire: *wireFlag == "binary",
+		OfflineHallCalls: *offlineHallFlag,
+		Journal:          recorder,
+	}
+
+	if *httpFlag != "" {
+		apiButtons := make(chan elevio.ButtonEvent)
+		config.Status = requests.NewNodeStatus()
+		config.ExternalButtons = apiButtons
+
+		server := api.NewServer(numFloors, config.Status, apiButtons)
+		go func() {
+			if err := server.ListenAndServe(*httpFlag); err != nil {
+				log.Error("HTTP API stopped", "err", err)
+			}
+		}()
+	}
+
+	fsmConfig := fsm.FSMConfig{
+		NumFloors:          numFloors,
+		ObstructionTimeout: *obstructionTimeoutFlag,
+		Journal:            recorder,
+	}
+	go fsm.RunElevFSM(driver, fsmConfig, requestsCh, completedRequestCh)
+	go requests.RequestControlLoop(driver, config, requestsCh, completedRequestCh)
 
 	select {}
 }
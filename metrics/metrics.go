@@ -0,0 +1,193 @@
+package metrics
+
+// Enkle counters og histogrammer som skrives ut i tekstformatet til Prometheus.
+// Alle metrikker registreres i en felles registry når de lages, og leses av Handler.
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	_mtx     sync.Mutex
+	_metrics = map[string]metric{}
+)
+
+func register(name string, m metric) {
+	_mtx.Lock()
+	defer _mtx.Unlock()
+	if _, exists := _metrics[name]; exists {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	_metrics[name] = m
+}
+
+type Counter struct {
+	name  string
+	help  string
+	value uint64
+}
+
+func NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	register(name, c)
+	return c
+}
+
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+func (c *Counter) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %d\n", c.name, c.Value())
+}
+
+// CounterVec er en counter med én label, f.eks. type knapp
+type CounterVec struct {
+	name   string
+	help   string
+	label  string
+	mtx    sync.Mutex
+	values map[string]uint64
+}
+
+func NewCounterVec(name, help, label string) *CounterVec {
+	c := &CounterVec{name: name, help: help, label: label, values: map[string]uint64{}}
+	register(name, c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValue string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.values[labelValue]++
+}
+
+func (c *CounterVec) Value(labelValue string) uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.values[labelValue]
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	labelValues := make([]string, 0, len(c.values))
+	for labelValue := range c.values {
+		labelValues = append(labelValues, labelValue)
+	}
+	sort.Strings(labelValues)
+	for _, labelValue := range labelValues {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", c.name, c.label, labelValue, c.values[labelValue])
+	}
+}
+
+// Histogram teller observasjoner i buckets med øvre grense, slik som i Prometheus
+type Histogram struct {
+	name    string
+	help    string
+	mtx     sync.Mutex
+	buckets []float64
+	counts  []uint64 // antall observasjoner i hver bucket, ikke kumulativt
+	sum     float64
+	count   uint64
+}
+
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+	h := &Histogram{name: name, help: help, buckets: sorted, counts: make([]uint64, len(sorted))}
+	register(name, h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	i := sort.SearchFloat64s(h.buckets, value)
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += value
+	h.count++
+}
+
+// ObserveDuration lagrer d i sekunder
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+func (h *Histogram) Count() uint64 {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.count
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	cumulative := uint64(0)
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", h.name, formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
+
+// WriteText skriver alle registrerte metrikker, sortert på navn
+func WriteText(w io.Writer) {
+	_mtx.Lock()
+	names := make([]string, 0, len(_metrics))
+	for name := range _metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	registered := make([]metric, len(names))
+	for i, name := range names {
+		registered[i] = _metrics[name]
+	}
+	_mtx.Unlock()
+
+	for _, m := range registered {
+		m.write(w)
+	}
+}
+
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteText(w)
+	})
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,253 @@
+package auth
+
+// Optional pre-shared-key authentication for the UDP broadcast packets.
+//
+// When a key is configured every datagram is sealed as
+//
+//	magic(2) | idLen(1) | senderID | seq(8) | payload | HMAC-SHA256(32)
+//
+// where the MAC covers everything before it. Receivers keep a sliding replay
+// window per sender, so old or repeated sequence numbers are dropped. Sequence
+// numbers start at the wall clock in nanoseconds, so they keep increasing when
+// a node restarts. The highest sequence number of each sender is kept for as
+// long as the receiver runs, also when the sender goes silent, so captured
+// frames from a crashed or partitioned node can not bring it back as a peer.
+// Without a key, Seal and Open pass the payload through unchanged.
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"project/clock"
+	"project/logging"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	macSize     = sha256.Size
+	seqSize     = 8
+	windowSize  = 64
+	logInterval = time.Second
+)
+
+var magic = [2]byte{'A', '1'}
+
+var log = logging.New("network")
+
+type Stats struct {
+	Accepted          uint64
+	RejectedMalformed uint64
+	RejectedMAC       uint64
+	RejectedReplay    uint64
+	RejectedIdentity  uint64
+}
+
+var (
+	_mtx      sync.RWMutex
+	_key      []byte
+	_senderID string
+	_seq      uint64
+
+	_accepted          uint64
+	_rejectedMalformed uint64
+	_rejectedMAC       uint64
+	_rejectedReplay    uint64
+	_rejectedIdentity  uint64
+)
+
+// Configure enables authentication with `key` for all transmitters and
+// receivers in this process. `senderID` identifies this node in the replay
+// window of the receivers, and must be unique in the fleet.
+func Configure(key []byte, senderID string) {
+	if len(senderID) > 255 {
+		panic(fmt.Sprintf("auth.Configure: sender ID longer than 255 bytes: %q", senderID))
+	}
+	_mtx.Lock()
+	defer _mtx.Unlock()
+	_key = append([]byte{}, key...)
+	_senderID = senderID
+	atomic.StoreUint64(&_seq, uint64(time.Now().UnixNano()))
+}
+
+func Enabled() bool {
+	_mtx.RLock()
+	defer _mtx.RUnlock()
+	return len(_key) > 0
+}
+
+// MaxOverhead is the most Seal can add to a payload, with a sender ID of 255 bytes
+const MaxOverhead = len(magic) + 1 + 255 + seqSize + macSize
+
+// Overhead is the number of bytes Seal adds to a payload
+func Overhead() int {
+	_mtx.RLock()
+	defer _mtx.RUnlock()
+	if len(_key) == 0 {
+		return 0
+	}
+	return len(magic) + 1 + len(_senderID) + seqSize + macSize
+}
+
+func GetStats() Stats {
+	return Stats{
+		Accepted:          atomic.LoadUint64(&_accepted),
+		RejectedMalformed: atomic.LoadUint64(&_rejectedMalformed),
+		RejectedMAC:       atomic.LoadUint64(&_rejectedMAC),
+		RejectedReplay:    atomic.LoadUint64(&_rejectedReplay),
+		RejectedIdentity:  atomic.LoadUint64(&_rejectedIdentity),
+	}
+}
+
+// Seal wraps `payload` in an authenticated frame
+func Seal(payload []byte) []byte {
+	_mtx.RLock()
+	key, senderID := _key, _senderID
+	_mtx.RUnlock()
+	if len(key) == 0 {
+		return payload
+	}
+
+	seq := atomic.AddUint64(&_seq, 1)
+	frame := make([]byte, 0, len(magic)+1+len(senderID)+seqSize+len(payload)+macSize)
+	frame = append(frame, magic[:]...)
+	frame = append(frame, byte(len(senderID)))
+	frame = append(frame, senderID...)
+	var seqBytes [seqSize]byte
+	binary.BigEndian.PutUint64(seqBytes[:], seq)
+	frame = append(frame, seqBytes[:]...)
+	frame = append(frame, payload...)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(frame)
+	return mac.Sum(frame)
+}
+
+type window struct {
+	highest uint64
+	seen    uint64 // bit i set means highest-i has been received
+}
+
+// Verifier checks frames received on one port. Each receiver has its own,
+// since sequence numbers are shared between all ports of a sender.
+type Verifier struct {
+	name    string
+	clk     clock.Clock
+	windows map[string]*window
+	lastLog time.Time
+	dropped uint64 // rejected since the last log line
+}
+
+func NewVerifier(name string) *Verifier {
+	return NewVerifierWithClock(name, clock.Real())
+}
+
+// NewVerifierWithClock uses clk to limit how often rejected frames are logged
+func NewVerifierWithClock(name string, clk clock.Clock) *Verifier {
+	return &Verifier{name: name, clk: clk, windows: make(map[string]*window)}
+}
+
+// Open verifies a frame from Seal and returns the payload, or false if the
+// frame must be dropped
+func (v *Verifier) Open(frame []byte) ([]byte, bool) {
+	payload, _, ok := v.open(frame)
+	return payload, ok
+}
+
+// OpenID opens a peers heartbeat, whose payload is the ID of the sender. The
+// ID must match the sender identity in the frame, so a node cannot announce
+// itself under the ID of another. Without a key the payload is returned as is.
+func (v *Verifier) OpenID(frame []byte) (string, bool) {
+	payload, senderID, ok := v.open(frame)
+	if !ok {
+		return "", false
+	}
+	if Enabled() && string(payload) != senderID {
+		v.reject(&_rejectedIdentity, fmt.Sprintf("heartbeat for %q sent by %q", payload, senderID))
+		return "", false
+	}
+	return string(payload), true
+}
+
+// open returns the payload and the authenticated sender ID, which is empty
+// when authentication is disabled
+func (v *Verifier) open(frame []byte) ([]byte, string, bool) {
+	_mtx.RLock()
+	key := _key
+	_mtx.RUnlock()
+	if len(key) == 0 {
+		return frame, "", true
+	}
+
+	headerSize := len(magic) + 1
+	if len(frame) < headerSize+seqSize+macSize || frame[0] != magic[0] || frame[1] != magic[1] {
+		v.reject(&_rejectedMalformed, "malformed packet")
+		return nil, "", false
+	}
+	idLen := int(frame[2])
+	if len(frame) < headerSize+idLen+seqSize+macSize {
+		v.reject(&_rejectedMalformed, "malformed packet")
+		return nil, "", false
+	}
+
+	body, receivedMAC := frame[:len(frame)-macSize], frame[len(frame)-macSize:]
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), receivedMAC) {
+		v.reject(&_rejectedMAC, "bad MAC")
+		return nil, "", false
+	}
+
+	senderID := string(body[headerSize : headerSize+idLen])
+	seq := binary.BigEndian.Uint64(body[headerSize+idLen : headerSize+idLen+seqSize])
+	if !v.checkReplay(senderID, seq) {
+		v.reject(&_rejectedReplay, fmt.Sprintf("replayed sequence number %d from %q", seq, senderID))
+		return nil, "", false
+	}
+
+	atomic.AddUint64(&_accepted, 1)
+	return body[headerSize+idLen+seqSize:], senderID, true
+}
+
+func (v *Verifier) checkReplay(senderID string, seq uint64) bool {
+	w, ok := v.windows[senderID]
+	if !ok {
+		v.windows[senderID] = &window{highest: seq, seen: 1}
+		return true
+	}
+	if seq > w.highest {
+		shift := seq - w.highest
+		if shift >= windowSize {
+			w.seen = 0
+		} else {
+			w.seen <<= shift
+		}
+		w.seen |= 1
+		w.highest = seq
+		return true
+	}
+	offset := w.highest - seq
+	if offset >= windowSize {
+		return false
+	}
+	if w.seen&(1<<offset) != 0 {
+		return false
+	}
+	w.seen |= 1 << offset
+	return true
+}
+
+// counts the rejection, and logs at most once per logInterval so a flood
+// of bad packets does not flood the log too
+func (v *Verifier) reject(counter *uint64, reason string) {
+	atomic.AddUint64(counter, 1)
+	v.dropped++
+	if v.clk.Now().Sub(v.lastLog) < logInterval {
+		return
+	}
+	log.Warn("Dropped unauthenticated packets", "receiver", v.name, "dropped", v.dropped, "last", reason)
+	v.lastLog = v.clk.Now()
+	v.dropped = 0
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"project/clock"
+	"testing"
+	"time"
+)
+
+// seals payload as senderID with sequence number seq
+func sealAs(senderID string, seq uint64, payload []byte) []byte {
+	Configure([]byte("test key"), senderID)
+	_seq = seq - 1
+	return Seal(payload)
+}
+
+func TestReplayedFrameIsRejected(t *testing.T) {
+	verifier := NewVerifierWithClock("test", clock.NewFake())
+	frame := sealAs("a", 100, []byte("hello"))
+	if payload, ok := verifier.Open(frame); !ok || string(payload) != "hello" {
+		t.Fatal("first frame was rejected")
+	}
+	if _, ok := verifier.Open(frame); ok {
+		t.Fatal("replayed frame was accepted")
+	}
+	if _, ok := verifier.Open(sealAs("a", 100-windowSize, []byte("hello"))); ok {
+		t.Fatal("frame older than the window was accepted")
+	}
+}
+
+// fanget trafikk fra en node som har vært borte lenge skal ikke få den tilbake som peer
+func TestReplayFromSilentSenderIsRejected(t *testing.T) {
+	clk := clock.NewFake()
+	verifier := NewVerifierWithClock("test", clk)
+	old := sealAs("a", 1000, []byte("a"))
+	last := sealAs("a", 1000+windowSize, []byte("a"))
+	for _, frame := range [][]byte{old, last} {
+		if _, ok := verifier.OpenID(frame); !ok {
+			t.Fatal("frame was rejected before the sender went silent")
+		}
+	}
+
+	clk.Advance(time.Hour)
+	if _, ok := verifier.OpenID(old); ok {
+		t.Fatal("captured frame below the window accepted after the sender was silent")
+	}
+	if _, ok := verifier.OpenID(last); ok {
+		t.Fatal("last frame replayed after the sender was silent")
+	}
+	if _, ok := verifier.OpenID(sealAs("a", 1001+windowSize, []byte("a"))); !ok {
+		t.Fatal("new frame rejected after the sender was silent")
+	}
+}
+
+func TestOpenIDChecksSenderIdentity(t *testing.T) {
+	verifier := NewVerifierWithClock("test", clock.NewFake())
+	if id, ok := verifier.OpenID(sealAs("a", 1, []byte("a"))); !ok || id != "a" {
+		t.Fatal("heartbeat with matching ID was rejected")
+	}
+	before := GetStats().RejectedIdentity
+	if _, ok := verifier.OpenID(sealAs("b", 1, []byte("a"))); ok {
+		t.Fatal("heartbeat for another ID was accepted")
+	}
+	if GetStats().RejectedIdentity != before+1 {
+		t.Fatal("identity rejection was not counted")
+	}
+}
@@ -1,17 +1,21 @@
 package bcast
 
 import (
+	"project/logging"
+	"project/network/auth"
 	"project/network/conn"
-	"encoding/json"
 	"fmt"
 	"net"
 	"reflect"
+	"time"
 )
 
 const bufSize = 1024
 
-// Encodes received values from `chans` into type-tagged JSON, then broadcasts
-// it on `port`
+var log = logging.New("network")
+
+// Encodes received values from `chans` into type-tagged JSON, or with the
+// registered Codec for the type, then broadcasts it on `port`
 func Transmitter(port int, chans ...interface{}) {
 	checkArgs(chans...)
 	typeNames := make([]string, len(chans))
@@ -21,26 +25,32 @@ func Transmitter(port int, chans ...interface{}) {
 			Dir:  reflect.SelectRecv,
 			Chan: reflect.ValueOf(ch),
 		}
-		typeNames[i] = reflect.TypeOf(ch).Elem().String()
+		typeNames[i] = typeId(reflect.TypeOf(ch).Elem())
 	}
 
 	conn := conn.DialBroadcastUDP(port)
 	addr, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf("255.255.255.255:%d", port))
 	for {
 		chosen, value, _ := reflect.Select(selectCases)
-		jsonstr, _ := json.Marshal(value.Interface())
-		ttj, _ := json.Marshal(typeTaggedJSON{
-			TypeId: typeNames[chosen],
-			JSON:   jsonstr,
-		})
-		if len(ttj) > bufSize {
-		    panic(fmt.Sprintf(
-		        "Tried to send a message longer than the buffer size (length: %d, buffer size: %d)\n\t'%s'\n"+
-		        "Either send smaller packets, or go to network/bcast/bcast.go and increase the buffer size",
-		        len(ttj), bufSize, string(ttj)))
+		msg, err := encode(typeNames[chosen], value.Interface())
+		if err != nil {
+			log.Error("bcast.Transmitter could not encode message", "port", port, "type", typeNames[chosen], "err", err)
+			packetsDropped.Inc("encode")
+			continue
+		}
+		fragments, err := fragment(msg, bufSize-auth.Overhead())
+		if err != nil {
+			log.Error("bcast.Transmitter dropping message", "port", port, "type", typeNames[chosen], "err", err)
+			packetsDropped.Inc("too_large")
+			continue
+		}
+		for _, frag := range fragments {
+			if _, err := conn.WriteTo(auth.Seal(frag), addr); err != nil {
+				packetsDropped.Inc("write")
+				continue
+			}
+			packetsSent.Inc()
 		}
-		conn.WriteTo(ttj, addr)
-    		
 	}
 }
 
@@ -51,24 +61,48 @@ func Receiver(port int, chans ...interface{}) {
 	chansMap := make(map[string]interface{})
 	for _, ch := range chans {
 		chansMap[reflect.TypeOf(ch).Elem().String()] = ch
+		chansMap[typeId(reflect.TypeOf(ch).Elem())] = ch
 	}
 
 	var buf [bufSize]byte
 	conn := conn.DialBroadcastUDP(port)
+	verifier := auth.NewVerifier(fmt.Sprintf("bcast %d", port))
+	fragments := newReassembler()
 	for {
-		n, _, e := conn.ReadFrom(buf[0:])
+		n, source, e := conn.ReadFrom(buf[0:])
 		if e != nil {
-			fmt.Printf("bcast.Receiver(%d, ...):ReadFrom() failed: \"%+v\"\n", port, e)
+			log.Error("bcast.Receiver ReadFrom failed", "port", port, "err", e)
+		}
+		if n == 0 {
+			continue
 		}
+		packetsReceived.Inc()
 
-		var ttj typeTaggedJSON
-		json.Unmarshal(buf[0:n], &ttj)
-		ch, ok := chansMap[ttj.TypeId]
+		payload, ok := verifier.Open(buf[0:n])
 		if !ok {
+			packetsDropped.Inc("auth")
+			continue
+		}
+		sourceAddr := ""
+		if source != nil {
+			sourceAddr = source.String()
+		}
+		msg, complete := fragments.add(payload, sourceAddr, time.Now())
+		if !complete {
+			continue
+		}
+		typeName, payload, isBinary := splitMessage(msg)
+		ch, ok := chansMap[typeName]
+		if !ok {
+			packetsDropped.Inc("unknown_type")
 			continue
 		}
 		v := reflect.New(reflect.TypeOf(ch).Elem())
-		json.Unmarshal(ttj.JSON, v.Interface())
+		if err := decodePayload(payload, isBinary, v.Interface()); err != nil && isBinary {
+			log.Warn("bcast.Receiver could not decode message", "port", port, "type", typeName, "err", err)
+			packetsDropped.Inc("decode")
+			continue
+		}
 		reflect.Select([]reflect.SelectCase{{
 			Dir:  reflect.SelectSend,
 			Chan: reflect.ValueOf(ch),
@@ -0,0 +1,152 @@
+package bcast
+
+// Channel types can be sent with a Codec instead of type-tagged JSON:
+//
+//	binaryMagic(2) | nameLen(1) | typeName | codec payload
+//
+// Receivers decode every registered type in both formats, so a node can switch
+// a type to its codec while the rest of the fleet still sends JSON.
+//
+// The type name on the wire is the Go type string, unless a stable name is set
+// with RegisterTypeId. Receivers accept both.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var binaryMagic = [2]byte{0x00, 'B'}
+
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type codecEntry struct {
+	codec Codec
+	send  bool
+}
+
+var _codecMtx sync.RWMutex
+var _codecs = make(map[string]codecEntry) // key: Go type string
+var _typeIds = make(map[string]string)    // Go type string -> name on the wire
+
+// RegisterCodec lets Receiver decode values of the type of `sample` with
+// `codec`. If `send` is true, Transmitter also encodes them with it.
+// Must be called before the Transmitter/Receiver is started.
+func RegisterCodec(sample interface{}, codec Codec, send bool) {
+	_codecMtx.Lock()
+	defer _codecMtx.Unlock()
+	_codecs[reflect.TypeOf(sample).String()] = codecEntry{codec: codec, send: send}
+}
+
+// SetCodecSend switches a type with a registered codec between the codec and
+// JSON while the Transmitter is running
+func SetCodecSend(sample interface{}, send bool) {
+	_codecMtx.Lock()
+	defer _codecMtx.Unlock()
+	typeName := reflect.TypeOf(sample).String()
+	if entry, ok := _codecs[typeName]; ok {
+		entry.send = send
+		_codecs[typeName] = entry
+	}
+}
+
+// RegisterTypeId sets the name the type of `sample` is sent under, so that
+// renaming the Go type or its package does not change the protocol.
+// Must be called before the Transmitter/Receiver is started.
+func RegisterTypeId(sample interface{}, typeId string) {
+	if len(typeId) > 255 {
+		panic(fmt.Sprintf("bcast.RegisterTypeId: type id too long: %s", typeId))
+	}
+	_codecMtx.Lock()
+	defer _codecMtx.Unlock()
+	_typeIds[reflect.TypeOf(sample).String()] = typeId
+}
+
+// typeId gives the name values of type `t` are sent under
+func typeId(t reflect.Type) string {
+	_codecMtx.RLock()
+	defer _codecMtx.RUnlock()
+	if id, ok := _typeIds[t.String()]; ok {
+		return id
+	}
+	return t.String()
+}
+
+func lookupCodec(typeName string) (codecEntry, bool) {
+	_codecMtx.RLock()
+	defer _codecMtx.RUnlock()
+	entry, ok := _codecs[typeName]
+	return entry, ok
+}
+
+// Marshal encodes `v` the way Transmitter would put it on the wire
+func Marshal(v interface{}) ([]byte, error) {
+	return encode(typeId(reflect.TypeOf(v)), v)
+}
+
+// Unmarshal decodes a message from Marshal into `v`, which must be a pointer
+// to the type that was sent
+func Unmarshal(data []byte, v interface{}) error {
+	typeName, payload, isBinary := splitMessage(data)
+	elemType := reflect.TypeOf(v).Elem()
+	if typeName != typeId(elemType) && typeName != elemType.String() {
+		return fmt.Errorf("message has type %q, not %s", typeName, typeId(elemType))
+	}
+	return decodePayload(payload, isBinary, v)
+}
+
+func encode(typeName string, value interface{}) ([]byte, error) {
+	if entry, ok := lookupCodec(reflect.TypeOf(value).String()); ok && entry.send {
+		payload, err := entry.codec.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		msg := make([]byte, 0, len(binaryMagic)+1+len(typeName)+len(payload))
+		msg = append(msg, binaryMagic[:]...)
+		msg = append(msg, byte(len(typeName)))
+		msg = append(msg, typeName...)
+		return append(msg, payload...), nil
+	}
+
+	jsonstr, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(typeTaggedJSON{
+		TypeId: typeName,
+		JSON:   jsonstr,
+	})
+}
+
+// returns the type name and the payload of a message, isBinary tells which
+// format the payload is in
+func splitMessage(msg []byte) (typeName string, payload []byte, isBinary bool) {
+	if len(msg) >= len(binaryMagic)+1 && msg[0] == binaryMagic[0] && msg[1] == binaryMagic[1] {
+		nameLen := int(msg[2])
+		if len(msg) < 3+nameLen {
+			return "", nil, true
+		}
+		return string(msg[3 : 3+nameLen]), msg[3+nameLen:], true
+	}
+	var ttj typeTaggedJSON
+	json.Unmarshal(msg, &ttj)
+	return ttj.TypeId, ttj.JSON, false
+}
+
+var errNoCodec = errors.New("no codec registered")
+
+func decodePayload(payload []byte, isBinary bool, v interface{}) error {
+	if !isBinary {
+		return json.Unmarshal(payload, v)
+	}
+	entry, ok := lookupCodec(reflect.TypeOf(v).Elem().String())
+	if !ok {
+		return errNoCodec
+	}
+	return entry.codec.Unmarshal(payload, v)
+}
@@ -0,0 +1,204 @@
+package bcast
+
+// Messages that do not fit in one datagram are split into fragments:
+//
+//	fragmentMagic(2) | msgID(8) | index(2) | total(2) | data
+//
+// Messages that fit are sent as plain type-tagged JSON like before, which
+// never starts with fragmentMagic. Fragments may arrive in any order and
+// duplicates are ignored. A message that is not complete within
+// reassemblyTimeout is dropped.
+//
+// Messages are limited to maxMessageSize, which leaves room for the largest
+// status broadcast (255 floors, ten elevators). A receiver drops fragments
+// announcing more than that, and keeps at most maxPendingPerSource partial
+// messages per source address, so one sender cannot make it hold on to
+// large amounts of memory or push out the messages of the others.
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"project/network/auth"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	fragmentHeaderSize  = 2 + 8 + 2 + 2
+	maxMessageSize      = 1 << 20
+	minChunkSize        = bufSize - auth.MaxOverhead - fragmentHeaderSize
+	maxFragments        = (maxMessageSize + minChunkSize - 1) / minChunkSize
+	reassemblyTimeout   = 1 * time.Second
+	maxPendingMessages  = 64
+	maxPendingPerSource = 8
+)
+
+var fragmentMagic = [2]byte{0x00, 'F'}
+
+// message IDs are a random per-process prefix and a counter, so fragments
+// from different processes on the same host do not get mixed up
+var msgIDPrefix uint64
+var msgIDCounter uint32
+
+func init() {
+	var b [4]byte
+	rand.Read(b[:])
+	msgIDPrefix = uint64(binary.BigEndian.Uint32(b[:])) << 32
+}
+
+func nextMsgID() uint64 {
+	return msgIDPrefix | uint64(atomic.AddUint32(&msgIDCounter, 1))
+}
+
+// splits `msg` into datagrams of at most `maxSize` bytes
+func fragment(msg []byte, maxSize int) ([][]byte, error) {
+	if len(msg) > maxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes is larger than max %d", len(msg), maxMessageSize)
+	}
+	if len(msg) <= maxSize {
+		return [][]byte{msg}, nil
+	}
+	chunkSize := maxSize - fragmentHeaderSize
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("datagram size %d too small for fragment header", maxSize)
+	}
+	total := (len(msg) + chunkSize - 1) / chunkSize
+	if total > maxFragments {
+		return nil, fmt.Errorf("message of %d bytes needs %d fragments, max is %d", len(msg), total, maxFragments)
+	}
+
+	msgID := nextMsgID()
+	fragments := make([][]byte, 0, total)
+	for i := 0; i < total; i++ {
+		start := i * chunkSize
+		end := start + chunkSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+		frag := make([]byte, fragmentHeaderSize, fragmentHeaderSize+end-start)
+		copy(frag[0:2], fragmentMagic[:])
+		binary.BigEndian.PutUint64(frag[2:10], msgID)
+		binary.BigEndian.PutUint16(frag[10:12], uint16(i))
+		binary.BigEndian.PutUint16(frag[12:14], uint16(total))
+		frag = append(frag, msg[start:end]...)
+		fragments = append(fragments, frag)
+	}
+	return fragments, nil
+}
+
+func isFragment(datagram []byte) bool {
+	return len(datagram) >= fragmentHeaderSize && datagram[0] == fragmentMagic[0] && datagram[1] == fragmentMagic[1]
+}
+
+type partialMessage struct {
+	source    string
+	parts     [][]byte
+	received  int
+	size      int
+	firstSeen time.Time
+}
+
+type reassembler struct {
+	pending map[uint64]*partialMessage
+	dropped uint64 // messages that timed out or were evicted
+}
+
+func newReassembler() *reassembler {
+	return &reassembler{pending: make(map[uint64]*partialMessage)}
+}
+
+// add takes a datagram from `source` and returns the complete message once all
+// fragments have arrived. Datagrams that are not fragments are returned as they are.
+func (r *reassembler) add(datagram []byte, source string, now time.Time) ([]byte, bool) {
+	r.expire(now)
+	if !isFragment(datagram) {
+		return datagram, true
+	}
+
+	msgID := binary.BigEndian.Uint64(datagram[2:10])
+	index := int(binary.BigEndian.Uint16(datagram[10:12]))
+	total := int(binary.BigEndian.Uint16(datagram[12:14]))
+	if total == 0 || index >= total {
+		return nil, false
+	}
+	if total > maxFragments {
+		packetsDropped.Inc("oversized")
+		return nil, false
+	}
+
+	partial, ok := r.pending[msgID]
+	if !ok {
+		if r.pendingFrom(source) >= maxPendingPerSource {
+			r.evictOldest(source)
+		}
+		if len(r.pending) >= maxPendingMessages {
+			r.evictOldest("")
+		}
+		partial = &partialMessage{source: source, parts: make([][]byte, total), firstSeen: now}
+		r.pending[msgID] = partial
+	}
+	if partial.source != source || len(partial.parts) != total || partial.parts[index] != nil {
+		return nil, false // inconsistent or duplicate fragment
+	}
+	data := datagram[fragmentHeaderSize:]
+	if partial.size+len(data) > maxMessageSize {
+		delete(r.pending, msgID)
+		r.dropped++
+		packetsDropped.Inc("oversized")
+		return nil, false
+	}
+	// the receive buffer is reused, so the data must be copied
+	partial.parts[index] = append([]byte{}, data...)
+	partial.received++
+	partial.size += len(data)
+	if partial.received < total {
+		return nil, false
+	}
+
+	delete(r.pending, msgID)
+	msg := make([]byte, 0, partial.size)
+	for _, part := range partial.parts {
+		msg = append(msg, part...)
+	}
+	return msg, true
+}
+
+func (r *reassembler) expire(now time.Time) {
+	for msgID, partial := range r.pending {
+		if now.Sub(partial.firstSeen) > reassemblyTimeout {
+			delete(r.pending, msgID)
+			r.dropped++
+			packetsDropped.Inc("incomplete")
+		}
+	}
+}
+
+func (r *reassembler) pendingFrom(source string) int {
+	n := 0
+	for _, partial := range r.pending {
+		if partial.source == source {
+			n++
+		}
+	}
+	return n
+}
+
+// drops the oldest partial message from `source`, or from any source if it is empty
+func (r *reassembler) evictOldest(source string) {
+	var oldestID uint64
+	var oldest *partialMessage
+	for msgID, partial := range r.pending {
+		if source != "" && partial.source != source {
+			continue
+		}
+		if oldest == nil || partial.firstSeen.Before(oldest.firstSeen) {
+			oldestID, oldest = msgID, partial
+		}
+	}
+	if oldest != nil {
+		delete(r.pending, oldestID)
+		r.dropped++
+		packetsDropped.Inc("incomplete")
+	}
+}
@@ -0,0 +1,70 @@
+package bcast
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestFragmentRoundTripOutOfOrder(t *testing.T) {
+	msg := bytes.Repeat([]byte("0123456789"), 500)
+	fragments, err := fragment(msg, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newReassembler()
+	now := time.Now()
+	for i := len(fragments) - 1; i > 0; i-- {
+		if _, complete := r.add(fragments[i], "a", now); complete {
+			t.Fatal("message complete before the last fragment")
+		}
+	}
+	reassembled, complete := r.add(fragments[0], "a", now)
+	if !complete || !bytes.Equal(reassembled, msg) {
+		t.Fatal("message was not reassembled")
+	}
+}
+
+func TestFragmentRejectsMessageAboveMaxSize(t *testing.T) {
+	if _, err := fragment(make([]byte, maxMessageSize+1), bufSize); err == nil {
+		t.Fatal("message above maxMessageSize was fragmented")
+	}
+}
+
+// builds a single fragment announcing `total` fragments, without the rest of the message
+func fakeFragment(msgID uint64, index, total int) []byte {
+	frag := make([]byte, fragmentHeaderSize, fragmentHeaderSize+1)
+	copy(frag[0:2], fragmentMagic[:])
+	binary.BigEndian.PutUint64(frag[2:10], msgID)
+	binary.BigEndian.PutUint16(frag[10:12], uint16(index))
+	binary.BigEndian.PutUint16(frag[12:14], uint16(total))
+	return append(frag, 'x')
+}
+
+func TestReassemblerDropsOversizedTotal(t *testing.T) {
+	r := newReassembler()
+	r.add(fakeFragment(1, 0, maxFragments+1), "a", time.Now())
+	if len(r.pending) != 0 {
+		t.Fatal("fragment announcing more than maxFragments was kept")
+	}
+}
+
+func TestReassemblerLimitsPendingPerSource(t *testing.T) {
+	r := newReassembler()
+	now := time.Now()
+	r.add(fakeFragment(1000, 0, 2), "b", now)
+	for i := 0; i < 4*maxPendingPerSource; i++ {
+		r.add(fakeFragment(uint64(i), 0, 2), "a", now.Add(time.Duration(i)*time.Millisecond))
+	}
+	if n := r.pendingFrom("a"); n != maxPendingPerSource {
+		t.Fatalf("%d pending messages from one source, max is %d", n, maxPendingPerSource)
+	}
+	if _, found := r.pending[1000]; !found {
+		t.Fatal("a flooding source pushed out the message of another source")
+	}
+	// a fragment from another source is not mixed into the message
+	if _, complete := r.add(fakeFragment(1000, 1, 2), "a", now); complete {
+		t.Fatal("fragment from another source completed the message")
+	}
+}
@@ -0,0 +1,10 @@
+package bcast
+
+import "project/metrics"
+
+var (
+	packetsSent     = metrics.NewCounter("bcast_packets_sent_total", "Datagrams sent by bcast transmitters.")
+	packetsReceived = metrics.NewCounter("bcast_packets_received_total", "Datagrams read by bcast receivers.")
+	packetsDropped  = metrics.NewCounterVec("bcast_packets_dropped_total",
+		"Datagrams or messages dropped by bcast, by reason.", "reason")
+)
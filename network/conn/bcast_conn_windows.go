@@ -62,7 +62,7 @@ func DialBroadcastUDP(port int) net.PacketConn {
     }
 
 	conn, err := config.ListenPacket(context.Background(), "udp4", fmt.Sprintf(":%d", port)) 
-	if err != nil { fmt.Println("Error: net.ListenConfig.ListenPacket:", err) }
+	if err != nil { log.Error("net.ListenConfig.ListenPacket failed", "err", err) }
 
 	return conn
 }
\ No newline at end of file
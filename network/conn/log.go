@@ -0,0 +1,5 @@
+package conn
+
+import "project/logging"
+
+var log = logging.New("network")
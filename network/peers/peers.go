@@ -1,6 +1,8 @@
 package peers
 
 import (
+	"project/clock"
+	"project/network/auth"
 	"project/network/conn"
 	"fmt"
 	"net"
@@ -14,74 +16,115 @@ type PeerUpdate struct {
 	Lost  []string
 }
 
-const interval = 15 * time.Millisecond
-const timeout = 500 * time.Millisecond
+const HEARTBEAT_INTERVAL = 15 * time.Millisecond
+const PEER_TIMEOUT = 500 * time.Millisecond
 
 func Transmitter(port int, id string, transmitEnable <-chan bool) {
+	TransmitterWithClock(port, id, transmitEnable, clock.Real())
+}
+
+// TransmitterWithClock sender heartbeats med intervall fra clk
+func TransmitterWithClock(port int, id string, transmitEnable <-chan bool, clk clock.Clock) {
 
 	conn := conn.DialBroadcastUDP(port)
 	addr, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf("255.255.255.255:%d", port))
 
+	// én ticker for hele løkken. clk.After i hver runde ville latt en ny timer bli liggende i en
+	// virtuell klokke hver gang transmitEnable kom før den gikk ut
+	ticker := clk.NewTicker(HEARTBEAT_INTERVAL)
+	defer ticker.Stop()
+
 	enable := true
 	for {
 		select {
 		case enable = <-transmitEnable:
-		case <-time.After(interval):
+		case <-ticker.C():
 		}
 		if enable {
-			conn.WriteTo([]byte(id), addr)
+			conn.WriteTo(auth.Seal([]byte(id)), addr)
 		}
 	}
 }
 
 func Receiver(port int, peerUpdateCh chan<- PeerUpdate) {
+	ReceiverWithClock(port, peerUpdateCh, clock.Real())
+}
+
+// ReceiverWithClock bruker clk for å avgjøre når en peer er borte. Lesingen fra socketen
+// venter fortsatt i vanlig tid
+func ReceiverWithClock(port int, peerUpdateCh chan<- PeerUpdate, clk clock.Clock) {
 
 	var buf [1024]byte
-	var p PeerUpdate
-	lastSeen := make(map[string]time.Time)
+	tracker := NewTracker(clk)
 
 	conn := conn.DialBroadcastUDP(port)
+	verifier := auth.NewVerifierWithClock(fmt.Sprintf("peers %d", port), clk)
 
 	for {
-		updated := false
-
-		conn.SetReadDeadline(time.Now().Add(interval))
+		conn.SetReadDeadline(time.Now().Add(HEARTBEAT_INTERVAL))
 		n, _, _ := conn.ReadFrom(buf[0:])
 
-		id := string(buf[:n])
-
-		// Adding new connection
-		p.New = ""
-		if id != "" {
-			if _, idExists := lastSeen[id]; !idExists {
-				p.New = id
-				updated = true
+		id := ""
+		if n > 0 {
+			if heartbeatID, ok := verifier.OpenID(buf[:n]); ok {
+				id = heartbeatID
 			}
-
-			lastSeen[id] = time.Now()
 		}
 
-		// Removing dead connection
-		p.Lost = make([]string, 0)
-		for k, v := range lastSeen {
-			if time.Now().Sub(v) > timeout {
-				updated = true
-				p.Lost = append(p.Lost, k)
-				delete(lastSeen, k)
-			}
+		if p, updated := tracker.Update(id); updated {
+			peerUpdateCh <- p
 		}
+	}
+}
+
+// Tracker holder styr på når hver peer sist ble hørt fra. Brukes av Receiver, og av transporter
+// som ikke går over UDP
+type Tracker struct {
+	clk      clock.Clock
+	lastSeen map[string]time.Time
+}
 
-		// Sending update
-		if updated {
-			p.Peers = make([]string, 0, len(lastSeen))
+func NewTracker(clk clock.Clock) *Tracker {
+	return &Tracker{clk: clk, lastSeen: make(map[string]time.Time)}
+}
 
-			for k, _ := range lastSeen {
-				p.Peers = append(p.Peers, k)
-			}
+// Update registrerer en heartbeat fra id, eller ingen dersom id er tom, og fjerner peers som ikke
+// er hørt fra på PEER_TIMEOUT. Returnerer true dersom listen over peers er endret
+func (t *Tracker) Update(id string) (PeerUpdate, bool) {
+	var p PeerUpdate
+	updated := false
+	now := t.clk.Now()
+
+	// Adding new connection
+	if id != "" {
+		if _, idExists := t.lastSeen[id]; !idExists {
+			p.New = id
+			updated = true
+		}
 
-			sort.Strings(p.Peers)
-			sort.Strings(p.Lost)
-			peerUpdateCh <- p
+		t.lastSeen[id] = now
+	}
+
+	// Removing dead connection
+	p.Lost = make([]string, 0)
+	for k, v := range t.lastSeen {
+		if now.Sub(v) > PEER_TIMEOUT {
+			updated = true
+			p.Lost = append(p.Lost, k)
+			delete(t.lastSeen, k)
 		}
 	}
-}
\ No newline at end of file
+
+	if !updated {
+		return p, false
+	}
+	p.Peers = make([]string, 0, len(t.lastSeen))
+
+	for k, _ := range t.lastSeen {
+		p.Peers = append(p.Peers, k)
+	}
+
+	sort.Strings(p.Peers)
+	sort.Strings(p.Lost)
+	return p, true
+}
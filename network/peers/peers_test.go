@@ -0,0 +1,61 @@
+package peers
+
+import (
+	"project/clock"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrackerPeerLoss(t *testing.T) {
+	clk := clock.NewFake()
+	tracker := NewTracker(clk)
+
+	update, updated := tracker.Update("a")
+	if !updated || update.New != "a" || !reflect.DeepEqual(update.Peers, []string{"a"}) {
+		t.Fatal("new peer not reported:", update)
+	}
+	if _, updated := tracker.Update("b"); !updated {
+		t.Fatal("second peer not reported")
+	}
+
+	// b sender heartbeats, a er stille
+	elapsed := time.Duration(0)
+	for elapsed+HEARTBEAT_INTERVAL <= PEER_TIMEOUT {
+		clk.Advance(HEARTBEAT_INTERVAL)
+		elapsed += HEARTBEAT_INTERVAL
+		if update, updated := tracker.Update("b"); updated {
+			t.Fatalf("peers changed after %v: %+v", elapsed, update)
+		}
+	}
+
+	clk.Advance(HEARTBEAT_INTERVAL)
+	update, updated = tracker.Update("b")
+	if !updated {
+		t.Fatal("silent peer was not lost after PEER_TIMEOUT")
+	}
+	if !reflect.DeepEqual(update.Lost, []string{"a"}) || !reflect.DeepEqual(update.Peers, []string{"b"}) {
+		t.Fatal("wrong peer lost:", update)
+	}
+
+	// a kommer tilbake og meldes som ny
+	update, updated = tracker.Update("a")
+	if !updated || update.New != "a" || !reflect.DeepEqual(update.Peers, []string{"a", "b"}) {
+		t.Fatal("returning peer not reported:", update)
+	}
+}
+
+func TestTrackerKeepsPeerUntilTimeout(t *testing.T) {
+	clk := clock.NewFake()
+	tracker := NewTracker(clk)
+	tracker.Update("a")
+
+	clk.Advance(PEER_TIMEOUT)
+	if _, updated := tracker.Update(""); updated {
+		t.Fatal("peer lost at exactly PEER_TIMEOUT")
+	}
+	clk.Advance(time.Millisecond)
+	if update, updated := tracker.Update(""); !updated || !reflect.DeepEqual(update.Lost, []string{"a"}) {
+		t.Fatal("peer not lost after PEER_TIMEOUT:", update)
+	}
+}
@@ -0,0 +1,346 @@
+package wire
+
+// Binary encoding of datatypes.NetworkMsg for bcast. All integers are varints,
+// and every ID (sender, AwareList entries, cab request owners) is written once
+// in a string table and referred to by index.
+//
+// version 2 (the one we send):
+//
+//	version(1) | nStrings | strings... | senderIdx | flags(1) | behaviour | direction | floor
+//	| protocolVersion | nCapabilities | capabilityIdx...
+//	| nFloors | hall requests [nFloors][2] | nCabOwners | (ownerIdx | nFloors | cab requests [nFloors])...
+//
+// version 1 is the same without protocolVersion and the capabilities, and is still decoded.
+//
+// request: state | count | nAware | awareIdx...
+// string: len | bytes
+// flags: bit 0 Available, bit 1 MotorFault, bit 2 Obstructed, bit 3 CabRestoreRequest, bit 4 CabRestoreReply. Older decoders ignore bits they do not know
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"project/datatypes"
+	"project/elevio"
+	"sort"
+)
+
+const NETWORK_MSG_VERSION = 2
+const oldestNetworkMsgVersion = 1
+
+const (
+	flagAvailable         = 1 << 0
+	flagMotorFault        = 1 << 1
+	flagObstructed        = 1 << 2
+	flagCabRestoreRequest = 1 << 3
+	flagCabRestoreReply   = 1 << 4
+)
+
+// upper bound on lengths read from the wire, so a corrupt packet cannot make us allocate gigabytes
+const maxWireLength = 1 << 16
+
+var errTruncated = errors.New("truncated message")
+
+type NetworkMsgCodec struct{}
+
+func (NetworkMsgCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(datatypes.NetworkMsg)
+	if !ok {
+		return nil, fmt.Errorf("NetworkMsgCodec: cannot encode %T", v)
+	}
+
+	cabOwners := make([]string, 0, len(msg.AllCabRequests))
+	for ID := range msg.AllCabRequests {
+		cabOwners = append(cabOwners, ID)
+	}
+	sort.Strings(cabOwners) // samme melding gir alltid samme bytes
+
+	table := newStringTable()
+	table.add(msg.SenderID)
+	table.addAll(msg.Capabilities)
+	for f := range msg.SenderHallRequests {
+		for b := range msg.SenderHallRequests[f] {
+			table.addAll(msg.SenderHallRequests[f][b].AwareList)
+		}
+	}
+	for _, ID := range cabOwners {
+		table.add(ID)
+		for _, request := range msg.AllCabRequests[ID] {
+			table.addAll(request.AwareList)
+		}
+	}
+
+	enc := encoder{buf: make([]byte, 0, 256)}
+	enc.buf = append(enc.buf, NETWORK_MSG_VERSION)
+	enc.uvarint(uint64(len(table.strings)))
+	for _, str := range table.strings {
+		enc.uvarint(uint64(len(str)))
+		enc.buf = append(enc.buf, str...)
+	}
+
+	enc.uvarint(table.index[msg.SenderID])
+	flags := byte(0)
+	if msg.Available {
+		flags |= flagAvailable
+	}
+	if msg.MotorFault {
+		flags |= flagMotorFault
+	}
+	if msg.Obstructed {
+		flags |= flagObstructed
+	}
+	if msg.CabRestoreRequest {
+		flags |= flagCabRestoreRequest
+	}
+	if msg.CabRestoreReply {
+		flags |= flagCabRestoreReply
+	}
+	enc.buf = append(enc.buf, flags)
+	enc.varint(int64(msg.Behavior))
+	enc.varint(int64(msg.Direction))
+	enc.varint(int64(msg.Floor))
+	enc.varint(int64(msg.ProtocolVersion))
+	enc.uvarint(uint64(len(msg.Capabilities)))
+	for _, capability := range msg.Capabilities {
+		enc.uvarint(table.index[capability])
+	}
+
+	enc.uvarint(uint64(len(msg.SenderHallRequests)))
+	for f := range msg.SenderHallRequests {
+		for b := range msg.SenderHallRequests[f] {
+			enc.request(msg.SenderHallRequests[f][b], table)
+		}
+	}
+
+	enc.uvarint(uint64(len(cabOwners)))
+	for _, ID := range cabOwners {
+		enc.uvarint(table.index[ID])
+		cabRequests := msg.AllCabRequests[ID]
+		enc.uvarint(uint64(len(cabRequests)))
+		for _, request := range cabRequests {
+			enc.request(request, table)
+		}
+	}
+	return enc.buf, nil
+}
+
+func (NetworkMsgCodec) Unmarshal(data []byte, v interface{}) error {
+	out, ok := v.(*datatypes.NetworkMsg)
+	if !ok {
+		return fmt.Errorf("NetworkMsgCodec: cannot decode into %T", v)
+	}
+	if len(data) == 0 {
+		return errTruncated
+	}
+	version := data[0]
+	if version < oldestNetworkMsgVersion || version > NETWORK_MSG_VERSION {
+		return fmt.Errorf("NetworkMsgCodec: unsupported version %d", version)
+	}
+	dec := decoder{buf: data[1:]}
+
+	nStrings := dec.length()
+	strings := make([]string, 0, nStrings)
+	for i := 0; i < nStrings && dec.err == nil; i++ {
+		strings = append(strings, dec.string())
+	}
+	dec.strings = strings
+
+	msg := datatypes.NetworkMsg{}
+	msg.SenderID = dec.stringRef()
+	flags := dec.byte()
+	msg.Available = flags&flagAvailable != 0
+	msg.MotorFault = flags&flagMotorFault != 0
+	msg.Obstructed = flags&flagObstructed != 0
+	msg.CabRestoreRequest = flags&flagCabRestoreRequest != 0
+	msg.CabRestoreReply = flags&flagCabRestoreReply != 0
+	msg.Behavior = datatypes.ElevBehaviour(dec.varint())
+	msg.Direction = elevio.MotorDirection(dec.varint())
+	msg.Floor = int(dec.varint())
+	if version >= 2 {
+		msg.ProtocolVersion = int(dec.varint())
+		nCapabilities := dec.length()
+		for i := 0; i < nCapabilities && dec.err == nil; i++ {
+			msg.Capabilities = append(msg.Capabilities, dec.stringRef())
+		}
+	}
+
+	nFloors := dec.floors()
+	msg.SenderHallRequests = datatypes.NewHallRequests(nFloors)
+	for f := 0; f < nFloors && dec.err == nil; f++ {
+		for b := range msg.SenderHallRequests[f] {
+			msg.SenderHallRequests[f][b] = dec.request()
+		}
+	}
+
+	nCabOwners := dec.length()
+	msg.AllCabRequests = make(map[string][]datatypes.RequestType, nCabOwners)
+	for i := 0; i < nCabOwners && dec.err == nil; i++ {
+		ID := dec.stringRef()
+		nCabFloors := dec.floors()
+		cabRequests := datatypes.NewCabRequests(nCabFloors)
+		for f := 0; f < nCabFloors && dec.err == nil; f++ {
+			cabRequests[f] = dec.request()
+		}
+		msg.AllCabRequests[ID] = cabRequests
+	}
+
+	if dec.err != nil {
+		return dec.err
+	}
+	*out = msg
+	return nil
+}
+
+type stringTable struct {
+	strings []string
+	index   map[string]uint64
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{index: make(map[string]uint64)}
+}
+
+func (t *stringTable) add(str string) {
+	if _, ok := t.index[str]; ok {
+		return
+	}
+	t.index[str] = uint64(len(t.strings))
+	t.strings = append(t.strings, str)
+}
+
+func (t *stringTable) addAll(strs []string) {
+	for _, str := range strs {
+		t.add(str)
+	}
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uvarint(x uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, tmp[:binary.PutUvarint(tmp[:], x)]...)
+}
+
+func (e *encoder) varint(x int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, tmp[:binary.PutVarint(tmp[:], x)]...)
+}
+
+func (e *encoder) request(request datatypes.RequestType, table *stringTable) {
+	e.uvarint(uint64(request.State))
+	e.varint(int64(request.Count))
+	e.uvarint(uint64(len(request.AwareList)))
+	for _, ID := range request.AwareList {
+		e.uvarint(table.index[ID])
+	}
+}
+
+// decoder husker første feil, slik at Unmarshal bare trenger å sjekke err til slutt
+type decoder struct {
+	buf     []byte
+	strings []string
+	err     error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.buf) == 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return x
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return x
+}
+
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if n > maxWireLength {
+		d.fail(fmt.Errorf("length %d too large", n))
+		return 0
+	}
+	return int(n)
+}
+
+// antall etasjer kan ikke være flere enn -floors tillater, slik at en korrupt pakke ikke gir store tabeller
+func (d *decoder) floors() int {
+	n := d.length()
+	if n > datatypes.MAX_N_FLOORS {
+		d.fail(fmt.Errorf("floor count %d larger than max %d", n, datatypes.MAX_N_FLOORS))
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) string() string {
+	n := d.length()
+	if d.err != nil {
+		return ""
+	}
+	if len(d.buf) < n {
+		d.fail(errTruncated)
+		return ""
+	}
+	str := string(d.buf[:n])
+	d.buf = d.buf[n:]
+	return str
+}
+
+func (d *decoder) stringRef() string {
+	i := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if i >= uint64(len(d.strings)) {
+		d.fail(fmt.Errorf("string index %d out of range", i))
+		return ""
+	}
+	return d.strings[i]
+}
+
+func (d *decoder) request() datatypes.RequestType {
+	request := datatypes.RequestType{}
+	request.State = datatypes.RequestState(d.uvarint())
+	request.Count = int(d.varint())
+	nAware := d.length()
+	if nAware > 0 {
+		request.AwareList = make([]string, 0, nAware)
+	}
+	for i := 0; i < nAware && d.err == nil; i++ {
+		request.AwareList = append(request.AwareList, d.stringRef())
+	}
+	return request
+}
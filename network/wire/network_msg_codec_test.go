@@ -0,0 +1,150 @@
+package wire
+
+import (
+	"math/rand"
+	"project/datatypes"
+	"project/network/bcast"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+const DATAGRAM_SIZE = 1024 // samme som bufSize i bcast
+
+// lager en melding der omtrent halvparten av bestillingene er aktive og alle heisene er aware of dem
+func sampleMsg(numFloors int, numElevators int) datatypes.NetworkMsg {
+	IDs := make([]string, numElevators)
+	for i := range IDs {
+		IDs[i] = "elevator" + strconv.Itoa(i)
+	}
+
+	hallRequests := datatypes.NewHallRequests(numFloors)
+	for f := range hallRequests {
+		for b := range hallRequests[f] {
+			if (f+b)%2 == 0 {
+				hallRequests[f][b] = datatypes.RequestType{State: datatypes.Assigned, Count: 17 + f, AwareList: IDs}
+			}
+		}
+	}
+
+	allCabRequests := make(map[string][]datatypes.RequestType)
+	for i, ID := range IDs {
+		cabRequests := datatypes.NewCabRequests(numFloors)
+		for f := range cabRequests {
+			if (f+i)%2 == 0 {
+				cabRequests[f] = datatypes.RequestType{State: datatypes.Unassigned, Count: 3, AwareList: IDs[:1+i%numElevators]}
+			}
+		}
+		allCabRequests[ID] = cabRequests
+	}
+
+	return datatypes.NetworkMsg{
+		ProtocolVersion:    datatypes.PROTOCOL_VERSION,
+		Capabilities:       []string{datatypes.CAP_BINARY_WIRE, datatypes.CAP_CAB_RESTORE},
+		SenderID:           IDs[0],
+		Available:          true,
+		MotorFault:         true,
+		Obstructed:         true,
+		CabRestoreRequest:  true,
+		CabRestoreReply:    true,
+		Behavior:           datatypes.Moving,
+		Direction:          1,
+		Floor:              numFloors / 2,
+		SenderHallRequests: hallRequests,
+		AllCabRequests:     allCabRequests,
+	}
+}
+
+func TestNetworkMsgRoundTrip(t *testing.T) {
+	for _, numFloors := range []int{2, datatypes.DEFAULT_N_FLOORS, datatypes.MAX_N_FLOORS} {
+		msg := sampleMsg(numFloors, 3)
+		encoded, err := NetworkMsgCodec{}.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := datatypes.NetworkMsg{}
+		if err := (NetworkMsgCodec{}).Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("%d floors: %v", numFloors, err)
+		}
+		if !reflect.DeepEqual(decoded, msg) {
+			t.Fatalf("%d floors: decoded message differs\ngot:  %+v\nwant: %+v", numFloors, decoded, msg)
+		}
+	}
+}
+
+func TestNetworkMsgTruncated(t *testing.T) {
+	encoded, err := NetworkMsgCodec{}.Marshal(sampleMsg(datatypes.DEFAULT_N_FLOORS, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < len(encoded); n++ {
+		decoded := datatypes.NetworkMsg{}
+		if err := (NetworkMsgCodec{}).Unmarshal(encoded[:n], &decoded); err == nil {
+			t.Fatalf("message truncated to %d of %d bytes was accepted", n, len(encoded))
+		}
+	}
+}
+
+func TestNetworkMsgCorrupt(t *testing.T) {
+	encoded, err := NetworkMsgCodec{}.Marshal(sampleMsg(datatypes.DEFAULT_N_FLOORS, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsupported := append([]byte{NETWORK_MSG_VERSION + 1}, encoded[1:]...)
+	if err := (NetworkMsgCodec{}).Unmarshal(unsupported, &datatypes.NetworkMsg{}); err == nil {
+		t.Fatal("unsupported version was accepted")
+	}
+
+	// tilfeldige bytefeil skal gi en feil eller en melding, aldri panic
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		corrupt := append([]byte{}, encoded...)
+		for j := 0; j < 1+random.Intn(4); j++ {
+			corrupt[random.Intn(len(corrupt))] = byte(random.Intn(256))
+		}
+		(NetworkMsgCodec{}).Unmarshal(corrupt, &datatypes.NetworkMsg{})
+	}
+}
+
+func TestNetworkMsgFloorLimit(t *testing.T) {
+	encoded, err := NetworkMsgCodec{}.Marshal(sampleMsg(datatypes.MAX_N_FLOORS+1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (NetworkMsgCodec{}).Unmarshal(encoded, &datatypes.NetworkMsg{}); err == nil {
+		t.Fatalf("message with %d floors was accepted", datatypes.MAX_N_FLOORS+1)
+	}
+}
+
+// koder og dekoder meldingen slik bcast gjør, med JSON eller NetworkMsgCodec
+func benchmarkNetworkMsg(b *testing.B, useBinary bool) {
+	bcast.RegisterCodec(datatypes.NetworkMsg{}, NetworkMsgCodec{}, useBinary)
+	defer bcast.RegisterCodec(datatypes.NetworkMsg{}, NetworkMsgCodec{}, false)
+
+	msg := sampleMsg(datatypes.DEFAULT_N_FLOORS, 3)
+	encoded, err := bcast.Marshal(msg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		encoded, _ := bcast.Marshal(msg)
+		out := datatypes.NetworkMsg{}
+		if err := bcast.Unmarshal(encoded, &out); err != nil {
+			b.Fatal(err)
+		}
+	}
+	// ResetTimer nullstiller egne målinger, så størrelsen rapporteres til slutt
+	b.ReportMetric(float64(len(encoded)), "bytes/msg")
+	b.ReportMetric(float64((len(encoded)+DATAGRAM_SIZE-1)/DATAGRAM_SIZE), "datagrams/msg")
+}
+
+func BenchmarkNetworkMsgJSON(b *testing.B) {
+	benchmarkNetworkMsg(b, false)
+}
+
+func BenchmarkNetworkMsgBinary(b *testing.B) {
+	benchmarkNetworkMsg(b, true)
+}
@@ -0,0 +1,78 @@
+package replay
+
+import (
+	"fmt"
+	"project/datatypes"
+	"project/journal"
+	"project/requests"
+	"time"
+)
+
+// sammenligner når hver timer gikk ut i journalen og i replay
+func compareTimers(recorded, replayed []journal.Event) []string {
+	divergences := []string{}
+	recordedTimes := timerTimes(recorded)
+	replayedTimes := timerTimes(replayed)
+	for _, name := range []string{journal.TIMER_DOOR, journal.TIMER_MOVEMENT, journal.TIMER_OBSTRUCTION, journal.TIMER_CAB_RESTORE, journal.TIMER_ASSIGN, journal.TIMER_BROADCAST} {
+		want, got := recordedTimes[name], replayedTimes[name]
+		for i := 0; i < len(want) && i < len(got); i++ {
+			if absDuration(want[i]-got[i]) > TIMER_TOLERANCE_MS*time.Millisecond {
+				divergences = append(divergences, fmt.Sprintf("%s timer #%d fired at %v in replay, %v in journal",
+					name, i+1, got[i], want[i]))
+				break
+			}
+		}
+		if len(want) != len(got) {
+			divergences = append(divergences, fmt.Sprintf("%s timer fired %d times in replay, %d times in journal",
+				name, len(got), len(want)))
+		}
+	}
+	return divergences
+}
+
+func timerTimes(events []journal.Event) map[string][]time.Duration {
+	times := map[string][]time.Duration{}
+	for _, event := range events {
+		if event.Type == journal.EVENT_TIMER {
+			times[event.Timer] = append(times[event.Timer], event.Time)
+		}
+	}
+	return times
+}
+
+// en hall-lampe skal være på hvis og bare hvis bestillingen er Assigned
+func checkLamps(lamps [][datatypes.N_BUTTONS]bool, state requests.NodeState) []string {
+	warnings := []string{}
+	for f := 0; f < len(lamps) && f < len(state.HallRequests); f++ {
+		for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
+			assigned := state.HallRequests[f][b].State == datatypes.Assigned
+			if lamps[f][b] != assigned {
+				warnings = append(warnings, fmt.Sprintf("hall lamp floor %d button %d is %s, request is %v",
+					f, b, onOff(lamps[f][b]), state.HallRequests[f][b].State))
+			}
+		}
+	}
+	cabRequests := state.AllCabRequests[state.LocalID]
+	for f := 0; f < len(lamps) && f < len(cabRequests); f++ {
+		assigned := cabRequests[f].State == datatypes.Assigned
+		if lamps[f][datatypes.BT_CAB] != assigned {
+			warnings = append(warnings, fmt.Sprintf("cab lamp floor %d is %s, request is %v",
+				f, onOff(lamps[f][datatypes.BT_CAB]), cabRequests[f].State))
+		}
+	}
+	return warnings
+}
+
+func onOff(value bool) string {
+	if value {
+		return "on"
+	}
+	return "off"
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,61 @@
+package replay
+
+import (
+	"project/elevio"
+	"sync"
+)
+
+// driver gir fsm etasjesensor, stoppknapp og obstruction fra journalen. Lampene og motoren
+// havner i FakeDriver, slik at de kan leses etter replay
+type driver struct {
+	*elevio.FakeDriver
+
+	floors       chan int
+	stops        chan bool
+	obstructions chan bool
+
+	mtx       sync.Mutex
+	getFloors []int // svar på GetFloor i samme rekkefølge som da journalen ble skrevet
+}
+
+func newDriver(numFloors int, getFloors []int) *driver {
+	return &driver{
+		FakeDriver:   elevio.NewFakeDriver(numFloors),
+		floors:       make(chan int),
+		stops:        make(chan bool),
+		obstructions: make(chan bool),
+		getFloors:    getFloors,
+	}
+}
+
+// knappetrykk kommer fra journalen gjennom ExternalButtons i RequestConfig
+func (d *driver) PollButtons(receiver chan<- elevio.ButtonEvent) {}
+
+func (d *driver) PollFloorSensor(receiver chan<- int) {
+	for floor := range d.floors {
+		receiver <- floor
+	}
+}
+
+func (d *driver) PollStopButton(receiver chan<- bool) {
+	for value := range d.stops {
+		receiver <- value
+	}
+}
+
+func (d *driver) PollObstructionSwitch(receiver chan<- bool) {
+	for value := range d.obstructions {
+		receiver <- value
+	}
+}
+
+func (d *driver) GetFloor() int {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if len(d.getFloors) == 0 {
+		return d.FakeDriver.GetFloor()
+	}
+	floor := d.getFloors[0]
+	d.getFloors = d.getFloors[1:]
+	return floor
+}
@@ -0,0 +1,237 @@
+package replay
+
+// Kjører en node på nytt fra hendelsesjournalen. RequestControlLoop og RunElevFSM får samme input som da
+// journalen ble skrevet, på en virtuell klokke, slik at timere går ut på samme tidspunkt. Etter hver
+// hendelse og hver timer venter replay litt, slik at begge løkkene rekker å behandle den før neste.
+//
+// Timerne som går ut i replay sammenlignes med dem i journalen. Et avvik betyr at noden tok en annen vei enn
+// da journalen ble skrevet, f.eks. fordi rekkefølgen mellom fsm og requests ble en annen.
+
+import (
+	"bytes"
+	"fmt"
+	"project/clock"
+	"project/datatypes"
+	"project/elevator_control"
+	"project/elevio"
+	"project/fsm"
+	"project/journal"
+	"project/logging"
+	"project/network/peers"
+	"project/requests"
+	request_handler "project/requests/request_handler"
+	"time"
+)
+
+var log = logging.New("replay")
+
+const (
+	DEFAULT_SETTLE     = 2 * time.Millisecond
+	DELIVERY_TIMEOUT   = time.Second
+	TIMER_TOLERANCE_MS = 50 // største forskjell på tidspunkt for en timer før det regnes som avvik
+)
+
+type Options struct {
+	Settle time.Duration // sanntid å vente etter hver hendelse, 0 gir DEFAULT_SETTLE
+}
+
+type Result struct {
+	Header       journal.Header
+	Events       int
+	Duration     time.Duration // virtuell tid fra start til siste hendelse
+	MessagesSent uint64
+
+	Divergences []string // avvik fra journalen
+	Warnings    []string // f.eks. en hall-lampe som er på uten at bestillingen er Assigned
+
+	Elevator     datatypes.Elevator
+	State        requests.NodeState
+	ButtonLamps  [][datatypes.N_BUTTONS]bool
+	DoorOpenLamp bool
+	StopLamp     bool
+	Motor        elevio.MotorDirection
+}
+
+func RunFile(path string, options Options) (Result, error) {
+	header, events, err := journal.ReadFile(path)
+	if err != nil {
+		return Result{}, err
+	}
+	return Run(header, events, options)
+}
+
+// Run kan bare kalles én gang per prosess, siden tilstanden i elevator_control er global
+func Run(header journal.Header, events []journal.Event, options Options) (Result, error) {
+	result := Result{Header: header, Events: len(events)}
+	settle := options.Settle
+	if settle == 0 {
+		settle = DEFAULT_SETTLE
+	}
+	if header.NumFloors < 2 {
+		return result, fmt.Errorf("journal has invalid floor count %d", header.NumFloors)
+	}
+	assigner, err := request_handler.NewAssigner(header.Assigner)
+	if err != nil {
+		return result, err
+	}
+
+	getFloors := []int{}
+	var initialCabRequests []datatypes.RequestType
+	for _, event := range events {
+		switch event.Type {
+		case journal.EVENT_GET_FLOOR:
+			getFloors = append(getFloors, event.Floor)
+		case journal.EVENT_CAB_RESTORE:
+			if initialCabRequests == nil {
+				initialCabRequests = event.CabRequests
+			}
+		}
+	}
+
+	log.Info("Replaying journal", "localID", header.LocalID, "floors", header.NumFloors, "events", len(events))
+	clk := clock.NewVirtual(header.Start)
+	driver := newDriver(header.NumFloors, getFloors)
+	transport := newTransport()
+	buttons := make(chan elevio.ButtonEvent)
+	status := requests.NewNodeStatus()
+
+	// replay skriver sin egen journal, timerne i den sammenlignes med originalen til slutt
+	replayed := bytes.Buffer{}
+	recorder, err := journal.NewRecorder(&replayed, header, clk)
+	if err != nil {
+		return result, err
+	}
+
+	requestsCh := make(chan [][datatypes.N_BUTTONS]bool)
+	completedRequestCh := make(chan datatypes.ButtonEvent)
+	config := requests.RequestConfig{
+		LocalID:            header.LocalID,
+		NumFloors:          header.NumFloors,
+		Assigner:           assigner,
+		PreferBinaryWire:   header.PreferBinaryWire,
+		OfflineHallCalls:   header.OfflineHallCalls,
+		Status:             status,
+		ExternalButtons:    buttons,
+		Transport:          transport,
+		Clock:              clk,
+		Journal:            recorder,
+		InitialCabRequests: initialCabRequests,
+	}
+	fsmConfig := fsm.FSMConfig{
+		NumFloors:          header.NumFloors,
+		ObstructionTimeout: header.ObstructionTimeout,
+		Clock:              clk,
+		Journal:            recorder,
+	}
+
+	go fsm.RunElevFSM(driver, fsmConfig, requestsCh, completedRequestCh)
+	go requests.RequestControlLoop(driver, config, requestsCh, completedRequestCh)
+
+	select {
+	case <-transport.started:
+	case <-time.After(DELIVERY_TIMEOUT):
+		return result, fmt.Errorf("RequestControlLoop did not start")
+	}
+
+	settleFunc := func() { time.Sleep(settle) }
+	for _, event := range events {
+		// timere som startes på nytt av en utløsning blir med, siden klokken går ett steg av gangen
+		clk.AdvanceStepwise(header.Start.Add(event.Time), settleFunc)
+		delivered := true
+		switch event.Type {
+		case journal.EVENT_BUTTON:
+			delivered = event.Button != nil && sendButton(buttons, *event.Button)
+		case journal.EVENT_FLOOR:
+			driver.FakeDriver.SetFloor(event.Floor)
+			delivered = sendInt(driver.floors, event.Floor)
+		case journal.EVENT_STOP:
+			driver.FakeDriver.SetStop(event.Value)
+			delivered = sendBool(driver.stops, event.Value)
+		case journal.EVENT_OBSTRUCTION:
+			driver.FakeDriver.SetObstruction(event.Value)
+			delivered = sendBool(driver.obstructions, event.Value)
+		case journal.EVENT_NETWORK_MSG:
+			delivered = event.Msg != nil && sendMsg(transport.receive, *event.Msg)
+		case journal.EVENT_PEER_UPDATE:
+			delivered = event.PeerUpdate != nil && sendPeerUpdate(transport.peerUpdates, *event.PeerUpdate)
+		default:
+			// timere kommer fra den virtuelle klokken, get_floor og cab_restore er brukt før start
+			continue
+		}
+		if !delivered {
+			result.Divergences = append(result.Divergences,
+				fmt.Sprintf("%v: %s event was not consumed within %v", event.Time, event.Type, DELIVERY_TIMEOUT))
+		}
+		time.Sleep(settle)
+	}
+	if len(events) > 0 {
+		result.Duration = events[len(events)-1].Time
+	}
+
+	recorder.Close()
+	_, replayedEvents, err := journal.Read(&replayed)
+	if err != nil {
+		return result, fmt.Errorf("reading replayed journal: %v", err)
+	}
+	result.Divergences = append(result.Divergences, compareTimers(events, replayedEvents)...)
+
+	result.Elevator = elevator_control.GetElevator()
+	result.State = status.Get()
+	result.ButtonLamps = datatypes.NewOrders(header.NumFloors)
+	for f := 0; f < header.NumFloors; f++ {
+		for b := 0; b < datatypes.N_BUTTONS; b++ {
+			result.ButtonLamps[f][b] = driver.ButtonLamp(elevio.ButtonType(b), f)
+		}
+	}
+	result.DoorOpenLamp = driver.DoorOpenLamp()
+	result.StopLamp = driver.StopLamp()
+	result.Motor = driver.MotorDirection()
+	result.MessagesSent = transport.messagesSent()
+	result.Warnings = checkLamps(result.ButtonLamps, result.State)
+	return result, nil
+}
+
+func sendButton(ch chan<- elevio.ButtonEvent, btn elevio.ButtonEvent) bool {
+	select {
+	case ch <- btn:
+		return true
+	case <-time.After(DELIVERY_TIMEOUT):
+		return false
+	}
+}
+
+func sendInt(ch chan<- int, value int) bool {
+	select {
+	case ch <- value:
+		return true
+	case <-time.After(DELIVERY_TIMEOUT):
+		return false
+	}
+}
+
+func sendBool(ch chan<- bool, value bool) bool {
+	select {
+	case ch <- value:
+		return true
+	case <-time.After(DELIVERY_TIMEOUT):
+		return false
+	}
+}
+
+func sendMsg(ch chan<- datatypes.NetworkMsg, msg datatypes.NetworkMsg) bool {
+	select {
+	case ch <- msg:
+		return true
+	case <-time.After(DELIVERY_TIMEOUT):
+		return false
+	}
+}
+
+func sendPeerUpdate(ch chan<- peers.PeerUpdate, update peers.PeerUpdate) bool {
+	select {
+	case ch <- update:
+		return true
+	case <-time.After(DELIVERY_TIMEOUT):
+		return false
+	}
+}
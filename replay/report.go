@@ -0,0 +1,84 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"project/datatypes"
+	"project/elevio"
+)
+
+func (r Result) WriteReport(w io.Writer) {
+	fmt.Fprintf(w, "Replayed %d events for node %s (%d floors, assigner %s), %v of recorded time\n",
+		r.Events, r.Header.LocalID, r.Header.NumFloors, r.Header.Assigner, r.Duration)
+	fmt.Fprintf(w, "Messages sent: %d\n\n", r.MessagesSent)
+
+	fmt.Fprintf(w, "Elevator: floor %d, direction %s, behaviour %s, stop active %v\n",
+		r.Elevator.CurrentFloor, dirToS(r.Elevator.Direction), behToS(r.Elevator.State), r.Elevator.StopActive)
+	fmt.Fprintf(w, "Motor %s, door lamp %s, stop lamp %s\n\n", motorToS(r.Motor), onOff(r.DoorOpenLamp), onOff(r.StopLamp))
+
+	cabRequests := r.State.AllCabRequests[r.State.LocalID]
+	fmt.Fprintf(w, "%-6s %-28s %-28s %-22s %s\n", "floor", "hall up", "hall down", "cab", "lamps (up/down/cab)")
+	for f := len(r.ButtonLamps) - 1; f >= 0; f-- {
+		up, down, cab := "", "", ""
+		if f < len(r.State.HallRequests) {
+			up = requestToS(r.State.HallRequests[f][datatypes.BT_HallUP])
+			down = requestToS(r.State.HallRequests[f][datatypes.BT_HallDOWN])
+		}
+		if f < len(cabRequests) {
+			cab = requestToS(cabRequests[f])
+		}
+		fmt.Fprintf(w, "%-6d %-28s %-28s %-22s %s/%s/%s\n", f, up, down, cab,
+			onOff(r.ButtonLamps[f][datatypes.BT_HallUP]), onOff(r.ButtonLamps[f][datatypes.BT_HallDOWN]),
+			onOff(r.ButtonLamps[f][datatypes.BT_CAB]))
+	}
+	fmt.Fprintf(w, "Peers: %v, network connected: %v\n", r.State.PeerList, r.State.NetworkConnected)
+
+	if len(r.Warnings) > 0 {
+		fmt.Fprintln(w, "\nWarnings:")
+		for _, warning := range r.Warnings {
+			fmt.Fprintln(w, "  "+warning)
+		}
+	}
+	if len(r.Divergences) > 0 {
+		fmt.Fprintln(w, "\nReplay diverged from the journal:")
+		for _, divergence := range r.Divergences {
+			fmt.Fprintln(w, "  "+divergence)
+		}
+	} else {
+		fmt.Fprintln(w, "\nNo divergences: all timers fired as in the journal")
+	}
+}
+
+func requestToS(request datatypes.RequestType) string {
+	return fmt.Sprintf("%v #%d %v", request.State, request.Count, request.AwareList)
+}
+
+func dirToS(dir datatypes.Direction) string {
+	switch dir {
+	case datatypes.DIR_UP:
+		return "up"
+	case datatypes.DIR_DOWN:
+		return "down"
+	}
+	return "stop"
+}
+
+func behToS(beh datatypes.ElevBehaviour) string {
+	switch beh {
+	case datatypes.Moving:
+		return "moving"
+	case datatypes.DoorOpen:
+		return "doorOpen"
+	}
+	return "idle"
+}
+
+func motorToS(dir elevio.MotorDirection) string {
+	switch dir {
+	case elevio.MD_Up:
+		return "up"
+	case elevio.MD_Down:
+		return "down"
+	}
+	return "stop"
+}
@@ -0,0 +1,188 @@
+package requests
+
+// write-ahead journal for lokale cab requests, slik at de ikke går tapt dersom prosessen dør
+// før en peer har fått dem. Hver endring legges til som en post og fsynces før vi går videre.
+//
+// post: [lengde uint32][crc32 uint32][json av cabJournalRecord], little endian
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"project/datatypes"
+)
+
+const (
+	CAB_JOURNAL_HEADER_SIZE  = 8
+	CAB_JOURNAL_MAX_RECORD   = 64 * 1024
+	CAB_JOURNAL_COMPACT_SIZE = 1000 // antall poster før journalen skrives om til et øyeblikksbilde
+)
+
+var errCorruptRecord = errors.New("corrupt cab journal record")
+
+type cabJournalRecord struct {
+	Floor   int
+	Request datatypes.RequestType
+}
+
+type cabJournal struct {
+	path    string
+	file    *os.File
+	records int
+}
+
+// åpner journalen og spiller av postene. En ødelagt eller halvskrevet post på slutten
+// kuttes bort, og journalen skrives om med tilstanden som ble gjenopprettet
+func openCabJournal(path string, numFloors int) (*cabJournal, []datatypes.RequestType, error) {
+	cabRequests := datatypes.NewCabRequests(numFloors)
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, cabRequests, err
+	}
+
+	reader := bytes.NewReader(data)
+	for {
+		offset := len(data) - reader.Len()
+		record, err := readCabJournalRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Warn("Corrupt cab journal record, ignoring the rest", "path", path, "offset", offset, "err", err)
+			break
+		}
+		if record.Floor < 0 || record.Floor >= numFloors {
+			log.Warn("Cab journal record with floor out of range, ignoring it", "path", path, "floor", record.Floor)
+			continue
+		}
+		cabRequests[record.Floor] = record.Request
+	}
+
+	journal := &cabJournal{path: path}
+	if err := journal.compact(cabRequests); err != nil {
+		return nil, cabRequests, err
+	}
+	return journal, cabRequests, nil
+}
+
+func readCabJournalRecord(reader io.Reader) (cabJournalRecord, error) {
+	record := cabJournalRecord{}
+
+	header := [CAB_JOURNAL_HEADER_SIZE]byte{}
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		if err == io.EOF {
+			return record, io.EOF
+		}
+		return record, errCorruptRecord // halvskrevet header
+	}
+	length := binary.LittleEndian.Uint32(header[0:4])
+	checksum := binary.LittleEndian.Uint32(header[4:8])
+	if length > CAB_JOURNAL_MAX_RECORD {
+		return record, errCorruptRecord
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return record, errCorruptRecord
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return record, errCorruptRecord
+	}
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return record, errCorruptRecord
+	}
+	return record, nil
+}
+
+func encodeCabJournalRecord(record cabJournalRecord) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, CAB_JOURNAL_HEADER_SIZE+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[CAB_JOURNAL_HEADER_SIZE:], payload)
+	return buf, nil
+}
+
+// skriver en endring for én etasje til journalen og venter til den ligger på disk
+func (j *cabJournal) record(floor int, request datatypes.RequestType, cabRequests []datatypes.RequestType) {
+	if j == nil {
+		return
+	}
+	if j.records >= CAB_JOURNAL_COMPACT_SIZE {
+		if err := j.compact(cabRequests); err != nil {
+			log.Error("Cab journal compact failed", "err", err)
+		}
+		return // øyeblikksbildet inneholder allerede endringen
+	}
+
+	buf, err := encodeCabJournalRecord(cabJournalRecord{Floor: floor, Request: request})
+	if err != nil {
+		log.Error("Cab journal encode failed", "err", err)
+		return
+	}
+	if _, err := j.file.Write(buf); err != nil {
+		log.Error("Cab journal write failed", "err", err)
+		return
+	}
+	if err := j.file.Sync(); err != nil {
+		log.Error("Cab journal sync failed", "err", err)
+		return
+	}
+	j.records++
+}
+
+// skriver hele tilstanden til en ny fil og bytter den inn atomisk med rename
+func (j *cabJournal) compact(cabRequests []datatypes.RequestType) error {
+	tmpPath := j.path + ".tmp"
+	tmpFile, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	for floor, request := range cabRequests {
+		buf, err := encodeCabJournalRecord(cabJournalRecord{Floor: floor, Request: request})
+		if err == nil {
+			_, err = tmpFile.Write(buf)
+		}
+		if err != nil {
+			tmpFile.Close()
+			return err
+		}
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return err
+	}
+
+	if j.file != nil {
+		j.file.Close()
+	}
+	j.file, err = os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	j.records = len(cabRequests)
+	return nil
+}
+
+// lukker filen, journalen kan ikke brukes etterpå
+func (j *cabJournal) close() {
+	if j == nil || j.file == nil {
+		return
+	}
+	j.file.Close()
+	j.file = nil
+}
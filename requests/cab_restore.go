@@ -0,0 +1,81 @@
+package requests
+
+// handshake for å hente egne cab requests fra peers ved oppstart og når noden kobler seg til nettverket igjen.
+// Noden ber om dem med CabRestoreRequest, peers svarer med en statusmelding med CabRestoreReply, og
+// svarene flettes som vanlig etter Count. Fordelingen venter til alle peers har svart, eller til
+// CAB_RESTORE_TIMEOUT_MS har gått
+
+import (
+	"project/clock"
+	"project/datatypes"
+	"project/elevator_control"
+	"sort"
+	"time"
+)
+
+const CAB_RESTORE_TIMEOUT_MS = 1000
+
+type cabRestore struct {
+	active  bool
+	replied map[string]bool
+	timer   clock.Timer
+}
+
+// starter aktiv, slik at første fordeling ved oppstart venter på at nettverket kommer opp
+func newCabRestore(clk clock.Clock) *cabRestore {
+	return &cabRestore{
+		active:  true,
+		replied: make(map[string]bool),
+		timer:   clk.NewTimer(CAB_RESTORE_TIMEOUT_MS * time.Millisecond),
+	}
+}
+
+// begynner på nytt, kalles når noden kobler seg til nettverket
+func (r *cabRestore) start() {
+	r.active = true
+	r.replied = make(map[string]bool)
+	elevator_control.KillTimer(r.timer)
+	r.timer.Reset(CAB_RESTORE_TIMEOUT_MS * time.Millisecond)
+}
+
+func (r *cabRestore) finish() {
+	r.active = false
+	elevator_control.KillTimer(r.timer)
+}
+
+// noterer svar fra en peer. Peers uten CAP_CAB_RESTORE svarer aldri, for dem holder det å ha hørt fra dem
+func (r *cabRestore) received(msg datatypes.NetworkMsg) {
+	if !r.active {
+		return
+	}
+	if msg.CabRestoreReply || !isContainedIn([]string{datatypes.CAP_CAB_RESTORE}, msg.Capabilities) {
+		r.replied[msg.SenderID] = true
+	}
+}
+
+// alle andre peers har svart. Er noden alene ventes det til timeout, i tilfelle peers dukker opp
+func (r *cabRestore) allReplied(peerList []string, localID string) bool {
+	others := 0
+	for _, ID := range peerList {
+		if ID == localID {
+			continue
+		}
+		others++
+		if !r.replied[ID] {
+			return false
+		}
+	}
+	return others > 0
+}
+
+// peers som ikke har svart, til loggen
+func (r *cabRestore) missing(peerList []string, localID string) []string {
+	missing := []string{}
+	for _, ID := range peerList {
+		if ID != localID && !r.replied[ID] {
+			missing = append(missing, ID)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
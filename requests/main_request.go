@@ -3,11 +3,12 @@ package requests
 // skal håndtere koordinering av knappetrykk, network messages og fordeling av bestillinger mellom heisene
 
 import (
-	"fmt"
+	"project/clock"
 	"project/datatypes"
 	"project/elevator_control"
 	"project/elevio"
-	"project/network/bcast"
+	"project/journal"
+	"project/logging"
 	"project/network/peers"
 	request_handler "project/requests/request_handler"
 	"time"
@@ -20,14 +21,56 @@ const (
 	REQUEST_ASSIGNMENT_INTERVAL_MS = 1000
 )
 
-func RequestControlLoop(localID string, reqChan chan<- [datatypes.N_FLOORS][datatypes.N_BUTTONS]bool,
+var log = logging.New("requests")
+
+// innstillinger for RequestControlLoop, settes fra flaggene i main
+type RequestConfig struct {
+	LocalID          string
+	NumFloors        int
+	Assigner         request_handler.Assigner
+	CabJournalPath   string // tom streng skrur av journalen
+	PreferBinaryWire bool   // sender NetworkMsg binært når alle peers støtter det
+	OfflineHallCalls bool   // tar hall requests alene når noden er frakoblet, og avstemmer dem etter Count når den kobler seg til igjen
+
+	Status          *NodeStatus               // får en kopi av tilstanden etter hver hendelse, kan være nil
+	ExternalButtons <-chan elevio.ButtonEvent // knappetrykk fra andre kilder enn knappepanelet, f.eks. HTTP API-et
+
+	Transport          Transport                // nil gir UDPTransport på PEER_PORT og MSG_PORT
+	Clock              clock.Clock              // nil gir vanlig tid
+	Journal            *journal.Recorder        // skriver all input til hendelsesjournalen, kan være nil
+	InitialCabRequests []datatypes.RequestType  // brukes i stedet for cab-journalen dersom satt, f.eks. ved replay
+	Shared             *elevator_control.Shared // må være den samme som fsm bruker, nil gir den felles instansen
+	Done               <-chan struct{}          // RequestControlLoop returnerer når kanalen lukkes, kan være nil
+}
+
+func RequestControlLoop(driver elevio.ElevatorDriver, config RequestConfig, reqChan chan<- [][datatypes.N_BUTTONS]bool,
 	completedReqChan <-chan datatypes.ButtonEvent) {
 
-	fmt.Println("=== RequestControlLoop startet, ny versjon ===")
+	log.Info("RequestControlLoop started", "localID", config.LocalID, "floors", config.NumFloors)
+
+	localID := config.LocalID
+	numFloors := config.NumFloors
+	assigner := config.Assigner
+	cabJournalPath := config.CabJournalPath
+	clk := clock.OrReal(config.Clock)
+	recorder := config.Journal
+	shared := elevator_control.OrDefault(config.Shared)
+	transport := config.Transport
+	if transport == nil {
+		transport = UDPTransport{PeerPort: PEER_PORT, MsgPort: MSG_PORT, Clock: clk}
+	}
 
 	// channel for butten event:
 	buttenEventChan := make(chan elevio.ButtonEvent)
-	go elevio.PollButtons(buttenEventChan)
+	go driver.PollButtons(buttenEventChan)
+	if config.ExternalButtons != nil {
+		// knappetrykk utenfra behandles likt som trykk på knappepanelet
+		go func() {
+			for btn := range config.ExternalButtons {
+				buttenEventChan <- btn
+			}
+		}()
+	}
 
 	// channels for sending/receiving messages
 	sendMessageChan := make(chan datatypes.NetworkMsg)
@@ -36,69 +79,152 @@ func RequestControlLoop(localID string, reqChan chan<- [datatypes.N_FLOORS][data
 	peerUpdateChan := make(chan peers.PeerUpdate)
 
 	// go rutines for network:
-	go peers.Receiver(PEER_PORT, peerUpdateChan)
-	go peers.Transmitter(PEER_PORT, localID, nil)
-	go bcast.Receiver(MSG_PORT, receiveMessageChan)
-	go bcast.Transmitter(MSG_PORT, sendMessageChan)
+	transport.Start(localID, sendMessageChan, receiveMessageChan, peerUpdateChan)
 
-	broadcastTicker := time.NewTicker(STATUS_UPDATE_INTERVAL_MS * time.Millisecond)
-	assignRequestTicker := time.NewTicker(REQUEST_ASSIGNMENT_INTERVAL_MS * time.Millisecond)
+	broadcastTicker := clk.NewTicker(STATUS_UPDATE_INTERVAL_MS * time.Millisecond)
+	assignRequestTicker := clk.NewTicker(REQUEST_ASSIGNMENT_INTERVAL_MS * time.Millisecond)
 
 	peerList := []string{}
 
 	isNetworkConnected := false
+	protocol := newProtocolNegotiator(config.PreferBinaryWire)
 
-	hallRequests := [datatypes.N_FLOORS][datatypes.N_HALL_BUTTONS]datatypes.RequestType{}
-	allCabRequests := make(map[string][datatypes.N_FLOORS]datatypes.RequestType)
+	hallRequests := datatypes.NewHallRequests(numFloors)
+	allCabRequests := make(map[string][]datatypes.RequestType)
 	updatedInfoElevs := make(map[string]datatypes.ElevatorInfo)
+	// hall requests trykket her mens noden var frakoblet og som ikke er tatt ennå, se OfflineHallCalls
+	offlinePresses := make([][datatypes.N_HALL_BUTTONS]bool, numFloors)
 
-	// initialiserer den lokale heisinformasjonen med localID:
-	allCabRequests[localID] = [datatypes.N_FLOORS]datatypes.RequestType{}
-	updatedInfoElevs[localID] = elevator_control.GetInfoElev()
+	// initialiserer den lokale heisinformasjonen med localID, cab requests hentes fra journalen før første broadcast:
+	allCabRequests[localID] = datatypes.NewCabRequests(numFloors)
+	var cabJournal *cabJournal
+	if len(config.InitialCabRequests) == numFloors {
+		allCabRequests[localID] = datatypes.CopyCabRequests(config.InitialCabRequests)
+	} else if cabJournalPath != "" {
+		opened, restoredCabReqs, err := openCabJournal(cabJournalPath, numFloors)
+		if err != nil {
+			log.Error("Could not open cab journal, cab requests will not be persisted", "path", cabJournalPath, "err", err)
+		} else {
+			cabJournal = opened
+			allCabRequests[localID] = restoredCabReqs
+		}
+	}
+	for f := 0; f < numFloors; f++ {
+		if allCabRequests[localID][f].State == datatypes.Assigned {
+			driver.SetButtonLamp(elevio.BT_Cab, f, true)
+		}
+	}
+	recorder.CabRestore(allCabRequests[localID])
+	updatedInfoElevs[localID] = shared.GetInfoElev()
+
+	lastAssignment := datatypes.NewOrders(numFloors)
+	lastAssignmentTime := time.Time{}
+	requestTimes := newRequestTimer()
+
+	// lager statusmeldingen som broadcastes, med tilstanden slik den er nå
+	statusMsg := func() datatypes.NetworkMsg {
+		info := updatedInfoElevs[localID]
+		return datatypes.NetworkMsg{
+			ProtocolVersion:    datatypes.PROTOCOL_VERSION,
+			Capabilities:       datatypes.LocalCapabilities(),
+			SenderID:           localID,
+			Available:          info.Available,
+			MotorFault:         info.MotorFault,
+			Obstructed:         info.Obstructed,
+			Behavior:           info.Behaviour,
+			Floor:              info.CurrentFloor,
+			Direction:          elevio.MotorDirection(info.Direction),
+			SenderHallRequests: datatypes.CopyHallRequests(hallRequests),
+			AllCabRequests:     datatypes.CopyAllCabRequests(allCabRequests),
+		}
+	}
+	sendMsg := func(msg datatypes.NetworkMsg) {
+		if !isNetworkConnected {
+			return
+		}
+		select {
+		case sendMessageChan <- msg:
+		case <-config.Done:
+		}
+	}
+
+	// kjører fordelingen og sender bestillingene til fsm dersom den er klar til å ta imot
+	assignRequests := func() {
+		orders := request_handler.RequestAssigner(assigner, hallRequests, allCabRequests, updatedInfoElevs, peerList, localID)
+		// fsm endrer tabellen den får, statusen beholder sin egen kopi
+		lastAssignment, lastAssignmentTime = append([][datatypes.N_BUTTONS]bool{}, orders...), clk.Now()
+		select {
+		case reqChan <- orders:
+		default:
+		}
+	}
+
+	// fordelingen venter til egne cab requests er hentet fra peers, se cab_restore.go
+	restore := newCabRestore(clk)
+	askForCabRequests := func() {
+		msg := statusMsg()
+		msg.CabRestoreRequest = true
+		sendMsg(msg)
+	}
+	finishRestore := func(reason string) {
+		restore.finish()
+		restored := 0
+		for f, request := range allCabRequests[localID] {
+			if request.State == datatypes.Assigned {
+				driver.SetButtonLamp(elevio.BT_Cab, f, true)
+				restored++
+			}
+		}
+		log.Info("Cab requests restored, starting assignment", "reason", reason, "assigned", restored,
+			"missingReplies", restore.missing(peerList, localID))
+		assignRequests()
+	}
 
 	// hovedloop - for-løkke med select
 	for {
 		select {
+		case <-config.Done:
+			cabJournal.close()
+			return
+
 		case btn := <-buttenEventChan:
-			fmt.Printf("DEBUG: Mottatt knappetrykk: Floor=%d, Button=%d\n", btn.Floor, btn.Button)
+			recorder.Button(btn)
+			buttonPresses.Inc(buttonToS(btn.Button))
 			request := datatypes.RequestType{}
 
 			if btn.Button == elevio.ButtonType(datatypes.BT_CAB) {
-				request = allCabRequests[localID][btn.Button]
+				request = allCabRequests[localID][btn.Floor]
 			} else {
-				if !isNetworkConnected {
-					fmt.Println("Network not connected, ignorerer hall request")
+				if !isNetworkConnected && !config.OfflineHallCalls {
+					log.Warn("Network not connected, ignoring hall request", "floor", btn.Floor, "button", buttonToS(btn.Button))
 					break // dersom ikke connected skal ikke hallrequesten legges til i requests
 				}
 				request = hallRequests[btn.Floor][btn.Button]
 			}
-			// switch case for å håndtere statusendringer for en forespørsel, basert på hva som skjer ved knappetrykk
-			fmt.Printf("DEBUG: Før endring: For floor %d, button %d, request state = %v\n", btn.Floor, btn.Button, request.State)
-			switch request.State {
-			case datatypes.Completed:
-				request.State = datatypes.Unassigned
-				request.AwareList = []string{localID} // setter at heis med localID er aware of denne request
-				if isContainedIn(peerList, request.AwareList) {
-					request.State = datatypes.Assigned
-					request.AwareList = []string{localID}
-					elevio.SetButtonLamp(btn.Button, btn.Floor, true)
-				}
-
-			case datatypes.Unassigned:
-				if isContainedIn(peerList, request.AwareList) {
-					request.State = datatypes.Assigned
-					request.AwareList = []string{localID}
-					elevio.SetButtonLamp(btn.Button, btn.Floor, true)
-				}
+			pressPeers := peerList
+			if !isNetworkConnected {
+				// frakoblet: noden er alene om requesten
+				pressPeers = []string{localID}
 			}
-			fmt.Printf("DEBUG: Etter endring: For floor %d, button %d, request state = %v\n", btn.Floor, btn.Button, request.State)
+			// statusendringen for en forespørsel ved knappetrykk
+			prevState := request.State
+			request = pressRequest(request, pressPeers, localID)
+			if request.State == datatypes.Assigned && prevState != datatypes.Assigned {
+				driver.SetButtonLamp(btn.Button, btn.Floor, true)
+			}
+			log.Debug("Button pressed", "floor", btn.Floor, "button", buttonToS(btn.Button), "from", prevState, "to", request.State)
 
 			if btn.Button == elevio.ButtonType(datatypes.BT_CAB) { // hvis det er en cab button
 				localCabReqs := allCabRequests[localID]
 				localCabReqs[btn.Floor] = request
 				allCabRequests[localID] = localCabReqs
+				cabJournal.record(btn.Floor, request, localCabReqs)
 			} else {
 				hallRequests[btn.Floor][btn.Button] = request
+				if !isNetworkConnected && prevState != datatypes.Assigned {
+					log.Info("Network not connected, taking hall request alone", "floor", btn.Floor, "button", buttonToS(btn.Button))
+					offlinePresses[btn.Floor][btn.Button] = true
+				}
 			}
 
 		case btn := <-completedReqChan:
@@ -108,136 +234,211 @@ func RequestControlLoop(localID string, reqChan chan<- [datatypes.N_FLOORS][data
 			} else {
 				request = hallRequests[btn.Floor][btn.Button]
 			}
-			// switch case med kun en case, for å sikre at det kun er tilfelles Assigned som blir håndtert:
-			switch request.State {
-			case datatypes.Assigned:
-				request.State = datatypes.Completed
-				request.AwareList = []string{localID}
-				request.Count++
-				elevio.SetButtonLamp(elevio.ButtonType(btn.Button), btn.Floor, false)
+			// kun en request som er Assigned blir fullført
+			if request.State == datatypes.Assigned {
+				request = completeRequest(request, localID)
+				driver.SetButtonLamp(elevio.ButtonType(btn.Button), btn.Floor, false)
 			}
 
 			if btn.Button == datatypes.BT_CAB {
 				localCabReqs := allCabRequests[localID]
 				localCabReqs[btn.Floor] = request
 				allCabRequests[localID] = localCabReqs
+				cabJournal.record(btn.Floor, request, localCabReqs)
 			} else {
 				hallRequests[btn.Floor][btn.Button] = request
+				offlinePresses[btn.Floor][btn.Button] = false
 			}
-		case <-broadcastTicker.C:
-			info := elevator_control.GetInfoElev()
-			updatedInfoElevs[localID] = info
+		case <-broadcastTicker.C():
+			recorder.Timer(journal.TIMER_BROADCAST)
+			updatedInfoElevs[localID] = shared.GetInfoElev()
+			newMsg := statusMsg()
+			// spør på nytt så lenge ikke alle har svart, i tilfelle en melding har gått tapt
+			newMsg.CabRestoreRequest = restore.active
+			sendMsg(newMsg)
 
-			newMsg := datatypes.NetworkMsg{
-				SenderID:           localID,
-				Available:          info.Available,
-				Behavior:           info.Behaviour,
-				Floor:              info.CurrentFloor,
-				Direction:          elevio.MotorDirection(info.Direction),
-				SenderHallRequests: hallRequests,
-				AllCabRequests:     allCabRequests,
+		case <-assignRequestTicker.C():
+			recorder.Timer(journal.TIMER_ASSIGN)
+			if restore.active {
+				break
 			}
+			assignRequests()
 
-			fmt.Println("Sending state update | ID:", localID,
-				"| Floor:", newMsg.Floor,
-				"| Direction:", newMsg.Direction,
-				"| State:", newMsg.Behavior)
-
-			if isNetworkConnected {
-				sendMessageChan <- newMsg
-			}
-
-		case <-assignRequestTicker.C:
-			select {
-			case reqChan <- request_handler.RequestAssigner(hallRequests, allCabRequests, updatedInfoElevs, peerList, localID):
-			default:
-
+		case <-restore.timer.C():
+			recorder.Timer(journal.TIMER_CAB_RESTORE)
+			if restore.active {
+				finishRestore("timeout")
 			}
 		case peer := <-peerUpdateChan:
+			recorder.PeerUpdate(peer)
 			peerList = peer.Peers
 
 			if peer.New == localID {
+				// koblet til ved oppstart eller etter å ha vært borte, henter egne cab requests før fordelingen fortsetter
 				isNetworkConnected = true
+				log.Info("Connected to network, asking peers for cab requests", "peers", peerList)
+				restore.start()
+				askForCabRequests()
+			} else if peer.New != "" && restore.active {
+				askForCabRequests()
 			}
 			if isContainedIn([]string{localID}, peer.Lost) {
 				isNetworkConnected = false
+				if config.OfflineHallCalls {
+					// ingen andre kan bli aware av requests som venter på enighet, noden tar dem alene
+					for f := 0; f < numFloors; f++ {
+						for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
+							var promoted bool
+							hallRequests[f][b], promoted = promoteIfAllAware(hallRequests[f][b], []string{localID}, localID)
+							if promoted {
+								driver.SetButtonLamp(elevio.ButtonType(b), f, true)
+							}
+						}
+					}
+					log.Warn("Network not connected, serving hall requests alone until reconnected")
+				}
 			}
+			protocol.forget(peer.Lost)
+			protocol.negotiate(peerList, localID)
 
-		case msg := <-sendMessageChan:
+			if isNetworkConnected && len(peer.Lost) > 0 {
+				for f := 0; f < numFloors; f++ {
+					for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
+						var promoted bool
+						hallRequests[f][b], promoted = promoteIfAllAware(hallRequests[f][b], peerList, localID)
+						if promoted {
+							driver.SetButtonLamp(elevio.ButtonType(b), f, true)
+						}
+					}
+				}
+				for ID, cabReqs := range allCabRequests {
+					for f := range cabReqs {
+						var promoted bool
+						cabReqs[f], promoted = promoteIfAllAware(cabReqs[f], peerList, localID)
+						if promoted && ID == localID {
+							driver.SetButtonLamp(elevio.BT_Cab, f, true)
+							cabJournal.record(f, cabReqs[f], cabReqs)
+						}
+					}
+				}
+			}
+
+		case msg := <-receiveMessageChan:
 			if msg.SenderID == localID {
 				break // godtar ikke message dersom avsender er seg selv
 			}
+			recorder.NetworkMsg(msg)
 			if !isNetworkConnected {
 				break // godtar ikke message dersom ikke connected til network
 			}
+			protocol.observe(msg)
+			protocol.negotiate(peerList, localID)
+			if len(msg.SenderHallRequests) != numFloors {
+				log.Warn("Ignoring message with wrong number of floors", "sender", msg.SenderID,
+					"floors", len(msg.SenderHallRequests), "expected", numFloors)
+				break // avsender er satt opp med et annet antall etasjer
+			}
+			prevInfo, knownSender := updatedInfoElevs[msg.SenderID]
 			updatedInfoElevs[msg.SenderID] = datatypes.ElevatorInfo{
 				Behaviour:    msg.Behavior,
 				Direction:    datatypes.Direction(msg.Direction),
 				Available:    msg.Available,
+				MotorFault:   msg.MotorFault,
+				Obstructed:   msg.Obstructed,
 				CurrentFloor: msg.Floor,
 			}
+			// ny motorfeil eller blokkert dør hos en peer: fordeler hall requests på nytt med en gang, uten å vente på assignRequestTicker
+			newMotorFault := msg.MotorFault && (!knownSender || !prevInfo.MotorFault)
+			newObstruction := msg.Obstructed && (!knownSender || !prevInfo.Obstructed)
+			if newMotorFault {
+				log.Warn("Motor fault reported by peer, reassigning hall requests", "sender", msg.SenderID)
+			}
+			if newObstruction {
+				log.Warn("Peer out of service with obstructed door, reassigning hall requests", "sender", msg.SenderID)
+			}
+			reassignNow := newMotorFault || newObstruction
 			for ID, cabReqs := range msg.AllCabRequests {
+				if len(cabReqs) != numFloors {
+					continue
+				}
 				if _, IDExists := allCabRequests[ID]; !IDExists {
 					// dette er da første informasjon om denne heisen
 					for floor := range cabReqs {
-						cabReqs[floor].AwareList = addIfMissing(cabReqs[floor].AwareList, localID) // TODO: definere denne
+						cabReqs[floor].AwareList = addIfMissing(cabReqs[floor].AwareList, localID)
 					}
 					allCabRequests[ID] = cabReqs
 					continue
 				}
-				for f := 0; f < datatypes.N_FLOORS; f++ {
-					if !canAcceptRequest(allCabRequests[ID][f], cabReqs[f]) { // sjekker om request kan aksepteres, hvis ikke hopper over denne f
+				for f := 0; f < numFloors; f++ {
+					acceptedReqs, accepted := mergeRequest(allCabRequests[ID][f], cabReqs[f], peerList, localID)
+					if !accepted {
 						continue
 					}
-					acceptedReqs := cabReqs[f]                                             // request fra aktuell f lagres acceptedReqs
-					acceptedReqs.AwareList = addIfMissing(acceptedReqs.AwareList, localID) // sørger for at localID er med i AwareList
-
-					// sjekker at requesten er unassigned og at alle peers er aware of denne request:
-					if acceptedReqs.State == datatypes.Unassigned && isContainedIn(peerList, acceptedReqs.AwareList) {
-						acceptedReqs.State = datatypes.Assigned
-						acceptedReqs.AwareList = []string{localID}
-					}
 
 					// sjekker at request gjelder lokal heis og om den er assigned:
 					if ID == localID && acceptedReqs.State == datatypes.Assigned {
 						// da settes buttonlamp for å indikere at heisen skal til den f:
-						elevio.SetButtonLamp(elevio.ButtonType(datatypes.BT_CAB), f, true)
+						driver.SetButtonLamp(elevio.ButtonType(datatypes.BT_CAB), f, true)
 					}
 
 					// for å oppdatere allCabRequests:
 					tempCabReqs := allCabRequests[ID]
 					tempCabReqs[f] = acceptedReqs
 					allCabRequests[ID] = tempCabReqs
+					if ID == localID {
+						cabJournal.record(f, acceptedReqs, tempCabReqs)
+					}
 				}
 			}
-			for f := 0; f < datatypes.N_FLOORS; f++ {
+			for f := 0; f < numFloors; f++ {
 				for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
 					// sjekker om inkommende request for gjeldende f og b skal aksepteres, dersom ikke - hopper over denne kombinasjonen
-					if !canAcceptRequest(hallRequests[f][b], msg.SenderHallRequests[f][b]) {
+					acceptedReqs, accepted := mergeRequest(hallRequests[f][b], msg.SenderHallRequests[f][b], peerList, localID)
+					if !accepted {
 						continue
 					}
-					acceptedReqs := msg.SenderHallRequests[f][b]
-					// legger til i awareList dersom localID ikke er der:
-					acceptedReqs.AwareList = addIfMissing(acceptedReqs.AwareList, localID)
-
-					// oppdaterer basert på state i acceptedReqs
-					switch acceptedReqs.State {
-					case datatypes.Assigned:
-						elevio.SetButtonLamp(elevio.ButtonType(b), f, true)
-					case datatypes.Unassigned:
-						elevio.SetButtonLamp(elevio.ButtonType(b), f, false) // skrur først av buttonlamp
-						if isContainedIn(peerList, acceptedReqs.AwareList) { // sjekker om alle peers er aware av request
-							acceptedReqs.State = datatypes.Assigned             // endrer da til assigned
-							acceptedReqs.AwareList = []string{localID}          // endrer slik at kun localID er aware
-							elevio.SetButtonLamp(elevio.ButtonType(b), f, true) // skrur på buttonlamp
+					if offlinePresses[f][b] {
+						offlinePresses[f][b] = false
+						if acceptedReqs.State == datatypes.Completed {
+							// de andre har tatt requesten med høyere Count mens noden var frakoblet, men trykket her
+							// er ikke tatt. Trykker på nytt, slik at det ikke går tapt
+							log.Info("Hall request pressed while offline was completed by peers, pressing again", "floor", f, "button", buttonToS(elevio.ButtonType(b)))
+							acceptedReqs = pressRequest(acceptedReqs, peerList, localID)
 						}
-					case datatypes.Completed: // hvis request er completed - skru av buttonlight
-						elevio.SetButtonLamp(elevio.ButtonType(b), f, false)
 					}
+					// buttonlamp lyser kun når requesten er assigned
+					driver.SetButtonLamp(elevio.ButtonType(b), f, acceptedReqs.State == datatypes.Assigned)
 					// oppdaterer hallRequests med aksepterte og evt endrede forespørsler:
 					hallRequests[f][b] = acceptedReqs
 				}
 			}
+			if msg.CabRestoreRequest {
+				reply := statusMsg()
+				reply.CabRestoreReply = true
+				sendMsg(reply)
+			}
+			restore.received(msg)
+			if restore.active && restore.allReplied(peerList, localID) {
+				finishRestore("all peers replied")
+			} else if reassignNow && !restore.active {
+				assignRequests()
+			}
+		}
+
+		requestTimes.observe(hallRequests, allCabRequests[localID], clk.Now())
+
+		if config.Status != nil {
+			config.Status.set(NodeState{
+				LocalID:            localID,
+				NetworkConnected:   isNetworkConnected,
+				HallRequests:       datatypes.CopyHallRequests(hallRequests),
+				AllCabRequests:     datatypes.CopyAllCabRequests(allCabRequests),
+				UpdatedInfoElevs:   copyInfoElevs(updatedInfoElevs),
+				PeerList:           append([]string{}, peerList...),
+				LastAssignment:     append([][datatypes.N_BUTTONS]bool{}, lastAssignment...),
+				LastAssignmentTime: lastAssignmentTime,
+				RestoringCabs:      restore.active,
+			})
 		}
 	}
 }
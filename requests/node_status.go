@@ -0,0 +1,52 @@
+package requests
+
+// kopi av tilstanden i RequestControlLoop som kan leses fra andre goroutines, f.eks. HTTP API-et
+
+import (
+	"project/datatypes"
+	"sync"
+	"time"
+)
+
+type NodeState struct {
+	LocalID            string
+	NetworkConnected   bool
+	HallRequests       [][datatypes.N_HALL_BUTTONS]datatypes.RequestType
+	AllCabRequests     map[string][]datatypes.RequestType
+	UpdatedInfoElevs   map[string]datatypes.ElevatorInfo
+	PeerList           []string
+	LastAssignment     [][datatypes.N_BUTTONS]bool // bestillingene fordelingen sist ga den lokale heisen
+	LastAssignmentTime time.Time
+	RestoringCabs      bool // venter på cab requests fra peers, fordelingen er ikke i gang
+}
+
+// NodeStatus deles mellom RequestControlLoop, som skriver, og de som leser
+type NodeStatus struct {
+	mtx   sync.RWMutex
+	state NodeState
+}
+
+func NewNodeStatus() *NodeStatus {
+	return &NodeStatus{}
+}
+
+// Get returnerer siste tilstand. Den skal bare leses, den deles med andre som kaller Get
+func (s *NodeStatus) Get() NodeState {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.state
+}
+
+func (s *NodeStatus) set(state NodeState) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.state = state
+}
+
+func copyInfoElevs(infoElevs map[string]datatypes.ElevatorInfo) map[string]datatypes.ElevatorInfo {
+	copied := make(map[string]datatypes.ElevatorInfo, len(infoElevs))
+	for ID, info := range infoElevs {
+		copied[ID] = info
+	}
+	return copied
+}
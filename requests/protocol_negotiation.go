@@ -0,0 +1,81 @@
+package requests
+
+// holder oversikt over protokollversjon og egenskaper til peers, og slår på egenskaper
+// først når alle peers har annonsert at de støtter dem
+
+import (
+	"project/datatypes"
+	"project/network/bcast"
+	"sort"
+)
+
+type protocolNegotiator struct {
+	preferBinaryWire bool
+	peerVersions     map[string]int
+	peerCapabilities map[string][]string
+	binaryWireActive bool
+}
+
+func newProtocolNegotiator(preferBinaryWire bool) *protocolNegotiator {
+	return &protocolNegotiator{
+		preferBinaryWire: preferBinaryWire,
+		peerVersions:     make(map[string]int),
+		peerCapabilities: make(map[string][]string),
+	}
+}
+
+// lagrer versjon og egenskaper fra en mottatt melding
+func (n *protocolNegotiator) observe(msg datatypes.NetworkMsg) {
+	version := msg.Version()
+	if prev, known := n.peerVersions[msg.SenderID]; !known || prev != version {
+		log.Info("Peer protocol", "peer", msg.SenderID, "version", version, "capabilities", msg.Capabilities)
+	}
+	n.peerVersions[msg.SenderID] = version
+	n.peerCapabilities[msg.SenderID] = msg.Capabilities
+}
+
+// glemmer peers som har forsvunnet. Kommer de tilbake, f.eks. med en ny versjon av noden, regnes de
+// som uten egenskaper til den første meldingen er mottatt
+func (n *protocolNegotiator) forget(lost []string) {
+	for _, ID := range lost {
+		delete(n.peerVersions, ID)
+		delete(n.peerCapabilities, ID)
+	}
+}
+
+// egenskapene alle noder i peerList støtter. En peer vi ikke har hørt fra ennå regnes som uten egenskaper
+func (n *protocolNegotiator) fleetCapabilities(peerList []string, localID string) []string {
+	common := []string{}
+	for _, capability := range datatypes.LocalCapabilities() {
+		supported := true
+		for _, ID := range peerList {
+			if ID == localID {
+				continue
+			}
+			if !isContainedIn([]string{capability}, n.peerCapabilities[ID]) {
+				supported = false
+				break
+			}
+		}
+		if supported {
+			common = append(common, capability)
+		}
+	}
+	sort.Strings(common)
+	return common
+}
+
+// velger koding av NetworkMsg ut fra hva alle peers støtter, kalles når peerList eller egenskapene endres
+func (n *protocolNegotiator) negotiate(peerList []string, localID string) {
+	useBinary := n.preferBinaryWire && isContainedIn([]string{datatypes.CAP_BINARY_WIRE}, n.fleetCapabilities(peerList, localID))
+	if useBinary == n.binaryWireActive {
+		return
+	}
+	n.binaryWireActive = useBinary
+	bcast.SetCodecSend(datatypes.NetworkMsg{}, useBinary)
+	if useBinary {
+		log.Info("All peers support binary wire, switching status broadcasts to binary", "capability", datatypes.CAP_BINARY_WIRE)
+	} else {
+		log.Info("Not all peers support binary wire, switching status broadcasts to JSON", "capability", datatypes.CAP_BINARY_WIRE)
+	}
+}
@@ -0,0 +1,398 @@
+package requests
+
+// modell av konsensusprotokollen for én hall-knapp. Hver node har sin RequestType og sitt syn på hvem
+// som er peers, og nettverket er en pose med meldinger per link som kan leveres i vilkårlig rekkefølge
+// eller forsvinne. Overgangene er de samme funksjonene RequestControlLoop bruker
+
+import (
+	"fmt"
+	"project/datatypes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// et knappetrykk modellen følger med på, for å kunne avgjøre om det ble tatt
+type modelPress struct {
+	node   int
+	cycle  int  // Count for requesten da knappen ble trykket
+	lit    bool // lampen hos noden ble tent etter trykket
+	served bool // en node har fullført requesten trykket hører til
+	stale  bool // trykket på en Count en annen node allerede hadde fullført, uten at noden visste om det
+}
+
+type modelState struct {
+	requests     []datatypes.RequestType
+	peers        []uint // bitmaske med nodene hver node regner som peers
+	alive        uint
+	offline      uint                      // noder som er frakoblet og tar hall requests alene, som med OfflineHallCalls
+	offlinePress uint                      // noder med et trykk fra mens de var frakoblet som ikke er avstemt ennå
+	links        [][]datatypes.RequestType // meldinger på vei, links[from*n+to]
+	presses      []modelPress
+	peerChanges  int
+	crashed      bool
+	maxCompleted int // høyeste Count en request er fullført med, -1 før første
+}
+
+var nodeIDs = []string{"n1", "n2", "n3", "n4", "n5", "n6", "n7", "n8"}
+
+func nodeID(i int) string {
+	return nodeIDs[i]
+}
+
+func newState(n int) *modelState {
+	s := &modelState{
+		requests:     make([]datatypes.RequestType, n),
+		peers:        make([]uint, n),
+		alive:        1<<uint(n) - 1,
+		links:        make([][]datatypes.RequestType, n*n),
+		maxCompleted: -1,
+	}
+	for i := range s.peers {
+		s.peers[i] = s.alive
+	}
+	return s
+}
+
+func (s *modelState) n() int {
+	return len(s.requests)
+}
+
+func (s *modelState) isAlive(i int) bool {
+	return s.alive&(1<<uint(i)) != 0
+}
+
+func (s *modelState) isOffline(i int) bool {
+	return s.offline&(1<<uint(i)) != 0
+}
+
+func (s *modelState) peerList(i int) []string {
+	peerList := []string{}
+	for j := 0; j < s.n(); j++ {
+		if s.peers[i]&(1<<uint(j)) != 0 {
+			peerList = append(peerList, nodeID(j))
+		}
+	}
+	return peerList
+}
+
+func (s *modelState) clone() *modelState {
+	c := *s
+	c.requests = make([]datatypes.RequestType, len(s.requests))
+	for i, request := range s.requests {
+		c.requests[i] = copyRequest(request)
+	}
+	c.peers = append([]uint{}, s.peers...)
+	c.links = make([][]datatypes.RequestType, len(s.links))
+	for i, link := range s.links {
+		for _, msg := range link {
+			c.links[i] = append(c.links[i], copyRequest(msg))
+		}
+	}
+	c.presses = append([]modelPress{}, s.presses...)
+	return &c
+}
+
+func copyRequest(request datatypes.RequestType) datatypes.RequestType {
+	request.AwareList = append([]string{}, request.AwareList...)
+	return request
+}
+
+// requestToS skriver en request med sortert AwareList, slik at like tilstander får lik nøkkel
+func requestToS(request datatypes.RequestType) string {
+	var b strings.Builder
+	writeRequest(&b, request)
+	return b.String()
+}
+
+func writeRequest(b *strings.Builder, request datatypes.RequestType) {
+	aware := request.AwareList
+	if !sort.StringsAreSorted(aware) {
+		aware = append([]string{}, aware...)
+		sort.Strings(aware)
+	}
+	b.WriteString(request.State.String())
+	b.WriteByte('/')
+	b.WriteString(strconv.Itoa(request.Count))
+	b.WriteByte('[')
+	b.WriteString(strings.Join(aware, ","))
+	b.WriteByte(']')
+}
+
+// nøkkel for settet med besøkte tilstander
+func (s *modelState) key() string {
+	var b strings.Builder
+	for i, request := range s.requests {
+		writeRequest(&b, request)
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatUint(uint64(s.peers[i]), 16))
+		b.WriteByte(';')
+	}
+	b.WriteString(strconv.FormatUint(uint64(s.alive), 16))
+	b.WriteByte('|')
+	for i, link := range s.links {
+		if len(link) == 0 {
+			continue
+		}
+		msgs := make([]string, len(link))
+		for k, msg := range link {
+			msgs[k] = requestToS(msg)
+		}
+		sort.Strings(msgs)
+		b.WriteString(strconv.Itoa(i))
+		b.WriteByte('=')
+		b.WriteString(strings.Join(msgs, "+"))
+		b.WriteByte('|')
+	}
+	for _, p := range s.presses {
+		fmt.Fprintf(&b, "p%d/%d/%t/%t/%t", p.node, p.cycle, p.lit, p.served, p.stale)
+	}
+	fmt.Fprintf(&b, "|%d/%t/%d/%x/%x", s.peerChanges, s.crashed, s.maxCompleted, s.offline, s.offlinePress)
+	return b.String()
+}
+
+func (s *modelState) String() string {
+	parts := []string{}
+	for i, request := range s.requests {
+		if !s.isAlive(i) {
+			parts = append(parts, nodeID(i)+"=crashed")
+			continue
+		}
+		status := ""
+		if s.isOffline(i) {
+			status = " offline"
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s peers{%s}%s", nodeID(i), requestToS(request), strings.Join(s.peerList(i), ","), status))
+	}
+	inFlight := 0
+	for _, link := range s.links {
+		inFlight += len(link)
+	}
+	return fmt.Sprintf("%s, %d in flight", strings.Join(parts, " "), inFlight)
+}
+
+// noterer trykk som har fått lampen tent hos noden der knappen ble trykket
+func (s *modelState) updatePresses() {
+	for k := range s.presses {
+		p := &s.presses[k]
+		if !p.served && s.requests[p.node].State == datatypes.Assigned {
+			p.lit = true
+		}
+	}
+}
+
+// en overgang fra en tilstand, med en beskrivelse til sporet
+type modelStep struct {
+	label string
+	next  *modelState
+}
+
+func (s *modelState) successors(config exploreConfig) []modelStep {
+	n := s.n()
+	steps := []modelStep{}
+
+	for i := 0; i < n; i++ {
+		if !s.isAlive(i) {
+			continue
+		}
+		if len(s.presses) < config.MaxPresses {
+			t := s.clone()
+			// frakoblet er peerList bare noden selv, som pressPeers i RequestControlLoop
+			t.requests[i] = pressRequest(t.requests[i], t.peerList(i), nodeID(i))
+			if s.isOffline(i) && s.requests[i].State != datatypes.Assigned {
+				t.offlinePress |= 1 << uint(i)
+			}
+			p := modelPress{node: i, cycle: t.requests[i].Count}
+			// et trykk på en request noden allerede venter på legger ikke til noe nytt, og er tatt når den
+			// requesten er tatt, selv om det skjedde hos en annen node rett før trykket
+			if s.requests[i].State != datatypes.Completed && p.cycle <= s.maxCompleted {
+				p.served = true
+			}
+			if s.requests[i].State == datatypes.Completed && p.cycle <= s.maxCompleted {
+				p.stale = true
+			}
+			t.presses = append(t.presses, p)
+			steps = append(steps, modelStep{"press " + nodeID(i), t})
+		}
+		if s.requests[i].State == datatypes.Assigned {
+			t := s.clone()
+			completedCount := t.requests[i].Count
+			t.requests[i] = completeRequest(t.requests[i], nodeID(i))
+			t.offlinePress &^= 1 << uint(i)
+			if completedCount > t.maxCompleted {
+				t.maxCompleted = completedCount
+			}
+			// bare trykk som kom før fullføringen er tatt av den
+			for k := range t.presses {
+				if t.presses[k].cycle <= completedCount {
+					t.presses[k].served = true
+				}
+			}
+			steps = append(steps, modelStep{"serve " + nodeID(i), t})
+		}
+		if s.isOffline(i) {
+			continue // frakoblet sender ikke noden noe
+		}
+		if t, sent := s.send(i, config.MaxInFlight); sent {
+			steps = append(steps, modelStep{"send " + nodeID(i), t})
+		}
+	}
+
+	for from := 0; from < n; from++ {
+		for to := 0; to < n; to++ {
+			link := s.links[from*n+to]
+			seen := map[string]bool{}
+			for k, msg := range link {
+				if seen[requestToS(msg)] {
+					continue // like meldinger gir samme etterfølger
+				}
+				seen[requestToS(msg)] = true
+				t := s.clone()
+				t.removeMsg(from, to, k)
+				t.deliver(to, msg)
+				steps = append(steps, modelStep{fmt.Sprintf("deliver %s->%s %s", nodeID(from), nodeID(to), requestToS(msg)), t})
+
+				t = s.clone()
+				t.removeMsg(from, to, k)
+				steps = append(steps, modelStep{fmt.Sprintf("drop %s->%s %s", nodeID(from), nodeID(to), requestToS(msg)), t})
+			}
+		}
+	}
+
+	if s.peerChanges < config.MaxPeerChanges {
+		for i := 0; i < n; i++ {
+			if !s.isAlive(i) {
+				continue
+			}
+			if config.AllowOffline {
+				t := s.clone()
+				t.peerChanges++
+				if s.isOffline(i) {
+					t.goOnline(i)
+					steps = append(steps, modelStep{nodeID(i) + " comes back online", t})
+				} else {
+					t.goOffline(i)
+					steps = append(steps, modelStep{nodeID(i) + " goes offline", t})
+				}
+			}
+			if s.isOffline(i) {
+				continue
+			}
+			for j := 0; j < n; j++ {
+				if i == j {
+					continue
+				}
+				bit := uint(1) << uint(j)
+				if s.peers[i]&bit != 0 {
+					t := s.clone()
+					t.peerChanges++
+					t.losePeer(i, j)
+					steps = append(steps, modelStep{fmt.Sprintf("%s loses %s", nodeID(i), nodeID(j)), t})
+				} else if s.isAlive(j) && !s.isOffline(j) {
+					t := s.clone()
+					t.peerChanges++
+					t.peers[i] |= bit
+					steps = append(steps, modelStep{fmt.Sprintf("%s sees %s again", nodeID(i), nodeID(j)), t})
+				}
+			}
+		}
+	}
+
+	if config.AllowCrash && !s.crashed {
+		for i := 0; i < n; i++ {
+			t := s.clone()
+			t.crashed = true
+			t.alive &^= 1 << uint(i)
+			for j := 0; j < n; j++ {
+				t.links[j*n+i] = nil // meldinger til noden forsvinner, de den har sendt kan fortsatt komme fram
+			}
+			steps = append(steps, modelStep{"crash " + nodeID(i), t})
+		}
+	}
+
+	for _, st := range steps {
+		st.next.updatePresses()
+	}
+	return steps
+}
+
+// legger nodens request på alle linker til levende noder som har plass og ikke har den fra før
+func (s *modelState) send(i int, maxInFlight int) (*modelState, bool) {
+	n := s.n()
+	t := s.clone()
+	sent := false
+	for j := 0; j < n; j++ {
+		if j == i || !s.isAlive(j) {
+			continue
+		}
+		link := t.links[i*n+j]
+		if len(link) >= maxInFlight {
+			continue
+		}
+		duplicate := false
+		for _, msg := range link {
+			if requestToS(msg) == requestToS(s.requests[i]) {
+				duplicate = true
+			}
+		}
+		if duplicate {
+			continue
+		}
+		t.links[i*n+j] = append(link, copyRequest(s.requests[i]))
+		sent = true
+	}
+	return t, sent
+}
+
+func (s *modelState) removeMsg(from int, to int, k int) {
+	n := s.n()
+	link := s.links[from*n+to]
+	s.links[from*n+to] = append(append([]datatypes.RequestType{}, link[:k]...), link[k+1:]...)
+}
+
+// returnerer true dersom mottakeren tok imot meldingen. En frakoblet node får ingen meldinger
+func (s *modelState) deliver(to int, msg datatypes.RequestType) bool {
+	if !s.isAlive(to) || s.isOffline(to) {
+		return false
+	}
+	merged, accepted := mergeRequest(s.requests[to], copyRequest(msg), s.peerList(to), nodeID(to))
+	if !accepted {
+		return false
+	}
+	bit := uint(1) << uint(to)
+	if s.offlinePress&bit != 0 {
+		// som i RequestControlLoop: de andre har fullført med høyere Count mens noden var frakoblet,
+		// så trykket fra da trykkes på nytt
+		s.offlinePress &^= bit
+		if merged.State == datatypes.Completed {
+			merged = pressRequest(merged, s.peerList(to), nodeID(to))
+		}
+	}
+	s.requests[to] = merged
+	return true
+}
+
+// som i RequestControlLoop når noden selv er i Lost: den er alene, og med OfflineHallCalls blir
+// requests den allerede er aware av Assigned
+func (s *modelState) goOffline(i int) {
+	s.offline |= 1 << uint(i)
+	s.peers[i] = 1 << uint(i)
+	s.requests[i], _ = promoteIfAllAware(s.requests[i], s.peerList(i), nodeID(i))
+	n := s.n()
+	for j := 0; j < n; j++ {
+		s.links[i*n+j] = nil // meldinger på vei til og fra noden går tapt
+		s.links[j*n+i] = nil
+	}
+}
+
+// noden hører seg selv og de levende nodene igjen
+func (s *modelState) goOnline(i int) {
+	s.offline &^= 1 << uint(i)
+	s.peers[i] = s.alive &^ s.offline
+}
+
+// som i RequestControlLoop: en peer forsvinner, og requests alle gjenværende er aware av blir Assigned
+func (s *modelState) losePeer(i int, j int) {
+	s.peers[i] &^= 1 << uint(j)
+	s.requests[i], _ = promoteIfAllAware(s.requests[i], s.peerList(i), nodeID(i))
+}
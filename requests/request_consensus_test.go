@@ -0,0 +1,422 @@
+package requests
+
+// utforsker alle tilstander modellen i request_consensus_model_test.go kan nå med bredde først, slik at
+// det første brudd på en invariant som blir funnet også har det korteste sporet
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"project/datatypes"
+	"strings"
+	"testing"
+)
+
+const (
+	DEFAULT_NODES            = 2
+	DEFAULT_MAX_PRESSES      = 2
+	DEFAULT_MAX_IN_FLIGHT    = 1
+	DEFAULT_MAX_PEER_CHANGES = 2
+	DEFAULT_MAX_STATES       = 1000000
+	MAX_NODES                = 8 // peers lagres som bitmaske, og tilstandsrommet er uansett for stort lenge før
+)
+
+// invariantene som sjekkes
+const (
+	INV_MONOTONIC_COUNT = "monotonic-count" // Count hos en node minker aldri
+	INV_NO_LOST_PRESS   = "no-lost-press"   // et trykk som har tent lampen blir tatt eller er fortsatt Assigned når nettverket er helt igjen
+	INV_NO_LIT_SERVED   = "no-lit-served"   // ingen lampe lyser for en request som allerede er tatt når nettverket er helt igjen
+	INV_CONVERGES       = "converges"       // nodene blir enige og ingen request blir stående Unassigned når nettverket er helt igjen
+
+	// kjent brudd, se checkExploreResult: et trykk på en Count en annen node allerede har fullført går tapt
+	INV_STALE_PRESS_LOST = "stale-press-lost"
+)
+
+type exploreConfig struct {
+	Nodes          int
+	MaxPresses     int  // knappetrykk totalt
+	MaxInFlight    int  // meldinger på vei per link
+	MaxPeerChanges int  // ganger en node mister eller får tilbake en peer, 0 gir et nettverk som aldri deles
+	AllowCrash     bool // én node kan krasje for godt
+	AllowOffline   bool // en node kan koble seg fra og tilbake, og tar da hall requests alene. Teller som peer-endring
+	MaxStates      int  // utforskningen stopper her, og resultatet er da ufullstendig
+	MaxDepth       int  // handlinger fra starttilstanden, 0 gir ingen grense. Alt innenfor grensen utforskes
+}
+
+type violation struct {
+	Invariant string
+	Message   string
+	Trace     []traceStep
+}
+
+type traceStep struct {
+	Action string // tom for starttilstanden
+	State  string
+}
+
+type exploreResult struct {
+	Config     exploreConfig
+	States     int
+	MaxDepth   int
+	Complete   bool // alle tilstander innenfor grensene ble utforsket
+	Violations []violation
+}
+
+// en tilstand i søket. Tilstanden selv slippes når den er utvidet, sporet bygges i stedet opp igjen
+// ved å gjenta handlingene fra starttilstanden
+type visited struct {
+	parent int
+	action string
+	depth  int
+	state  *modelState
+}
+
+func withDefaults(config exploreConfig) exploreConfig {
+	if config.Nodes <= 0 {
+		config.Nodes = DEFAULT_NODES
+	}
+	if config.Nodes > MAX_NODES {
+		config.Nodes = MAX_NODES
+	}
+	if config.MaxPresses <= 0 {
+		config.MaxPresses = DEFAULT_MAX_PRESSES
+	}
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = DEFAULT_MAX_IN_FLIGHT
+	}
+	if config.MaxPeerChanges < 0 {
+		config.MaxPeerChanges = 0
+	}
+	if config.MaxStates <= 0 {
+		config.MaxStates = DEFAULT_MAX_STATES
+	}
+	return config
+}
+
+// går gjennom tilstandsrommet og gir det korteste moteksempelet for hver invariant som brytes.
+// Besøkte tilstander huskes som en 64-bits hash av nøkkelen
+func explore(config exploreConfig) exploreResult {
+	config = withDefaults(config)
+	result := exploreResult{Config: config, Complete: true}
+
+	start := newState(config.Nodes)
+	nodes := []visited{{parent: -1, state: start}}
+	seen := map[uint64]bool{hashKey(start.key()): true}
+	violated := map[string]bool{}
+
+	report := func(index int, invariant string, message string, healed bool) {
+		if violated[invariant] {
+			return
+		}
+		violated[invariant] = true
+		result.Violations = append(result.Violations, violation{
+			Invariant: invariant,
+			Message:   message,
+			Trace:     trace(config, nodes, index, healed),
+		})
+	}
+
+	for index := 0; index < len(nodes); index++ {
+		current := nodes[index].state
+		nodes[index].state = nil
+		if nodes[index].depth > result.MaxDepth {
+			result.MaxDepth = nodes[index].depth
+		}
+		for invariant, message := range checkStabilized(current) {
+			report(index, invariant, message, true)
+		}
+		if config.MaxDepth > 0 && nodes[index].depth >= config.MaxDepth {
+			continue
+		}
+
+		for _, st := range current.successors(config) {
+			key := hashKey(st.next.key())
+			if seen[key] {
+				continue
+			}
+			if len(nodes) >= config.MaxStates {
+				result.Complete = false
+				break
+			}
+			seen[key] = true
+			nodes = append(nodes, visited{parent: index, action: st.label, depth: nodes[index].depth + 1, state: st.next})
+			if message, ok := checkMonotonic(current, st.next); !ok {
+				report(len(nodes)-1, INV_MONOTONIC_COUNT, message, false)
+			}
+		}
+	}
+	result.States = len(nodes)
+	return result
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// bygger sporet til tilstanden index ved å gjenta handlingene fra starttilstanden. Med healed kommer
+// stegene stabilize tar etter, slik at sporet viser hvordan tilstanden invarianten ble sjekket på oppsto
+func trace(config exploreConfig, nodes []visited, index int, healed bool) []traceStep {
+	actions := []string{}
+	for i := index; nodes[i].parent >= 0; i = nodes[i].parent {
+		actions = append([]string{nodes[i].action}, actions...)
+	}
+	s := newState(config.Nodes)
+	steps := []traceStep{{State: s.String()}}
+	for _, action := range actions {
+		for _, st := range s.successors(config) {
+			if st.label == action {
+				s = st.next
+				break
+			}
+		}
+		steps = append(steps, traceStep{Action: action, State: s.String()})
+	}
+	if healed {
+		_, healSteps := stabilize(s, true)
+		steps = append(steps, healSteps...)
+	}
+	return steps
+}
+
+func checkMonotonic(before *modelState, after *modelState) (string, bool) {
+	for i := range before.requests {
+		if after.requests[i].Count < before.requests[i].Count {
+			return fmt.Sprintf("Count at %s went from %d to %d", nodeID(i), before.requests[i].Count, after.requests[i].Count), false
+		}
+	}
+	return "", true
+}
+
+// leverer alt som er på vei, gjør alle levende noder til peers av hverandre og lar dem utveksle
+// tilstand til ingenting endres. Slik ser systemet ut når nettverket er helt igjen. Med record
+// returneres også stegene, med bare de meldingene som ble tatt imot
+func stabilize(s *modelState, record bool) (*modelState, []traceStep) {
+	t := s.clone()
+	n := t.n()
+	steps := []traceStep{}
+	note := func(action string) {
+		if record {
+			steps = append(steps, traceStep{Action: "heal: " + action, State: t.String()})
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if t.isAlive(i) && t.isOffline(i) {
+			t.offline &^= 1 << uint(i)
+			note(fmt.Sprintf("%s comes back online", nodeID(i)))
+		}
+	}
+	for i := 0; i < n; i++ {
+		if !t.isAlive(i) || t.peers[i] == t.alive {
+			continue
+		}
+		if t.peers[i]&^t.alive != 0 {
+			// som i RequestControlLoop når krasjede peers forsvinner
+			t.peers[i] &= t.alive
+			t.requests[i], _ = promoteIfAllAware(t.requests[i], t.peerList(i), nodeID(i))
+		}
+		t.peers[i] = t.alive
+		note(fmt.Sprintf("%s sees all live nodes", nodeID(i)))
+	}
+	for from := 0; from < n; from++ {
+		for to := 0; to < n; to++ {
+			link := t.links[from*n+to]
+			t.links[from*n+to] = nil
+			for _, msg := range link {
+				if t.deliver(to, msg) {
+					note(fmt.Sprintf("deliver %s->%s %s", nodeID(from), nodeID(to), requestToS(msg)))
+				}
+			}
+		}
+	}
+	for round := 0; round < 2*n+2; round++ {
+		changed := false
+		for from := 0; from < n; from++ {
+			if !t.isAlive(from) {
+				continue
+			}
+			for to := 0; to < n; to++ {
+				if to == from {
+					continue
+				}
+				msg := copyRequest(t.requests[from])
+				if t.deliver(to, msg) {
+					changed = true
+					note(fmt.Sprintf("exchange %s->%s %s", nodeID(from), nodeID(to), requestToS(msg)))
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return t, steps
+}
+
+// høyeste Count de levende nodene kan få vite om, fra egen tilstand eller meldinger på vei
+func visibleCount(s *modelState) int {
+	n := s.n()
+	count := 0
+	for i, request := range s.requests {
+		if s.isAlive(i) && request.Count > count {
+			count = request.Count
+		}
+	}
+	for from := 0; from < n; from++ {
+		for to := 0; to < n; to++ {
+			for _, msg := range s.links[from*n+to] {
+				if s.isAlive(to) && msg.Count > count {
+					count = msg.Count
+				}
+			}
+		}
+	}
+	return count
+}
+
+func checkStabilized(s *modelState) map[string]string {
+	violations := map[string]string{}
+	t, _ := stabilize(s, false)
+	// en fullføring bare en krasjet node visste om går tapt, og da er det riktig at de andre tar requesten på nytt
+	servedCount := t.maxCompleted
+	if visibleCount(s) <= servedCount {
+		servedCount = visibleCount(s) - 1
+	}
+	reference := -1
+	for i := range t.requests {
+		if !t.isAlive(i) {
+			continue
+		}
+		request := t.requests[i]
+		if reference == -1 {
+			reference = i
+		} else if request.State != t.requests[reference].State || request.Count != t.requests[reference].Count {
+			violations[INV_CONVERGES] = fmt.Sprintf("after healing %s has %s but %s has %s",
+				nodeID(i), requestToS(request), nodeID(reference), requestToS(t.requests[reference]))
+		}
+		if request.State == datatypes.Unassigned {
+			violations[INV_CONVERGES] = fmt.Sprintf("after healing %s is stuck at %s", nodeID(i), requestToS(request))
+		}
+		if request.State == datatypes.Assigned && request.Count <= servedCount {
+			violations[INV_NO_LIT_SERVED] = fmt.Sprintf("after healing %s keeps the lamp lit for %s, already served with Count %d",
+				nodeID(i), requestToS(request), servedCount)
+		}
+	}
+	for _, p := range s.presses {
+		if !p.lit || p.served || !s.isAlive(p.node) {
+			continue
+		}
+		if t.requests[p.node].State != datatypes.Assigned {
+			invariant := INV_NO_LOST_PRESS
+			if p.stale {
+				invariant = INV_STALE_PRESS_LOST
+			}
+			violations[invariant] = fmt.Sprintf("press at %s (Count %d) lit the lamp but after healing the request is %s without being served",
+				nodeID(p.node), p.cycle, requestToS(t.requests[p.node]))
+		}
+	}
+	return violations
+}
+
+func (r exploreResult) writeReport(w io.Writer) {
+	c := r.Config
+	fmt.Fprintf(w, "nodes=%d presses=%d inflight=%d peerchanges=%d crash=%t offline=%t depth=%d\n",
+		c.Nodes, c.MaxPresses, c.MaxInFlight, c.MaxPeerChanges, c.AllowCrash, c.AllowOffline, c.MaxDepth)
+	completeness := "complete"
+	if !r.Complete {
+		completeness = fmt.Sprintf("INCOMPLETE, stopped at %d states", c.MaxStates)
+	}
+	fmt.Fprintf(w, "explored %d states, max depth %d (%s)\n", r.States, r.MaxDepth, completeness)
+	if len(r.Violations) == 0 {
+		fmt.Fprintln(w, "no violations")
+		return
+	}
+	for _, v := range r.Violations {
+		fmt.Fprintf(w, "\nVIOLATION %s: %s\n", v.Invariant, v.Message)
+		for i, st := range v.Trace {
+			action := st.Action
+			if action == "" {
+				action = "start"
+			}
+			fmt.Fprintf(w, "  %2d. %-40s %s\n", i, action, st.State)
+		}
+	}
+}
+
+// handlingene i sporet, uten starttilstanden og stegene stabilize tar
+func traceActions(trace []traceStep) []string {
+	actions := []string{}
+	for _, st := range trace {
+		if st.Action != "" && !strings.HasPrefix(st.Action, "heal: ") {
+			actions = append(actions, st.Action)
+		}
+	}
+	return actions
+}
+
+// Count øker bare når en request fullføres. En node som er avskåret kan derfor trykke med samme Count
+// som en fullføring hos en annen node den ikke vet om. Blir trykket Assigned fordi noden mister peeren,
+// vinner den høyere Count til Completed over trykket når nettverket er helt igjen. Telleren alene kan
+// ikke skille dette fra et trykk som ble tatt, så bruddet er kjent og meldes som INV_STALE_PRESS_LOST.
+// Det korteste sporet må være nøyaktig known, og alle andre brudd får testen til å feile
+func checkExploreResult(t *testing.T, config exploreConfig, known []string) {
+	result := explore(config)
+	var report strings.Builder
+	result.writeReport(&report)
+
+	if !result.Complete {
+		t.Fatal("exploration did not complete, lower the bounds\n" + report.String())
+	}
+	foundKnown := false
+	for _, v := range result.Violations {
+		if v.Invariant != INV_STALE_PRESS_LOST {
+			t.Fatal(report.String())
+		}
+		if known == nil || strings.Join(traceActions(v.Trace), "; ") != strings.Join(known, "; ") {
+			t.Fatalf("stale press lost with a different counterexample than the known one %q\n%s", known, report.String())
+		}
+		foundKnown = true
+	}
+	if known != nil && !foundKnown {
+		t.Fatalf("known counterexample %q was not found, remove it from the test\n%s", known, report.String())
+	}
+	t.Log(report.String())
+}
+
+// n2 trykker på Count 0 etter at n1 har fullført den uten å få vite det, og blir Assigned når den mister n1
+var knownStalePress = []string{"press n1", "n1 loses n2", "serve n1", "press n2", "n2 loses n1"}
+
+// samme som knownStalePress, men n2 kobler seg fra etter trykket. Trykket var ikke frakoblet, så det
+// trykkes ikke på nytt når n2 kommer tilbake
+var knownStalePressOffline = []string{"press n1", "n1 goes offline", "serve n1", "press n2", "n2 goes offline"}
+
+// n1 tar trykket alene, og n2 trykker før den har fått med seg fullføringen
+var knownStalePressThreeNodes = []string{"press n1", "n1 loses n2", "n1 loses n3", "send n1", "serve n1",
+	"press n2", "deliver n1->n2 Assigned/0[n1]"}
+
+func TestConsensusTwoNodes(t *testing.T) {
+	checkExploreResult(t, exploreConfig{MaxPeerChanges: DEFAULT_MAX_PEER_CHANGES}, knownStalePress)
+}
+
+func TestConsensusTwoNodesCrash(t *testing.T) {
+	checkExploreResult(t, exploreConfig{MaxPeerChanges: DEFAULT_MAX_PEER_CHANGES, AllowCrash: true}, knownStalePress)
+}
+
+// uten at nodene mister hverandre kan ingen trykk gå tapt
+func TestConsensusTwoNodesNoPartition(t *testing.T) {
+	checkExploreResult(t, exploreConfig{MaxPresses: 3}, nil)
+}
+
+func TestConsensusTwoNodesOffline(t *testing.T) {
+	checkExploreResult(t, exploreConfig{MaxPeerChanges: DEFAULT_MAX_PEER_CHANGES, AllowOffline: true}, knownStalePressOffline)
+}
+
+// med tre noder vokser tilstandsrommet for fort til å utforskes helt, så dybden begrenses
+func TestConsensusThreeNodes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("three nodes explores many states, skipped with -short")
+	}
+	checkExploreResult(t, exploreConfig{Nodes: 3, MaxPresses: 2, MaxPeerChanges: 2, MaxDepth: 8}, knownStalePressThreeNodes)
+}
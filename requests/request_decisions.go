@@ -77,3 +77,65 @@ func isContainedIn(requiredSet []string, referenceSet []string) bool {
 	}
 	return true // ellers returneres true
 }
+
+// overgangene for én request i konsensusprotokollen. RequestControlLoop bruker dem for hver etasje og knapp,
+// og request_consensus_test.go utforsker dem direkte
+
+// knappetrykk: en Completed request blir Unassigned, og Assigned dersom alle peers er aware av den
+func pressRequest(request datatypes.RequestType, peerList []string, localID string) datatypes.RequestType {
+	switch request.State {
+	case datatypes.Completed:
+		request.State = datatypes.Unassigned
+		request.AwareList = []string{localID} // setter at heis med localID er aware of denne request
+		if isContainedIn(peerList, request.AwareList) {
+			request.State = datatypes.Assigned
+			request.AwareList = []string{localID}
+		}
+
+	case datatypes.Unassigned:
+		if isContainedIn(peerList, request.AwareList) {
+			request.State = datatypes.Assigned
+			request.AwareList = []string{localID}
+		}
+	}
+	return request
+}
+
+// heisen har tatt bestillingen, kun en Assigned request kan fullføres
+func completeRequest(request datatypes.RequestType, localID string) datatypes.RequestType {
+	if request.State == datatypes.Assigned {
+		request.State = datatypes.Completed
+		request.AwareList = []string{localID}
+		request.Count++
+	}
+	return request
+}
+
+// slår sammen den lokale requesten med en mottatt. Returnerer false dersom den mottatte ikke er ny informasjon
+func mergeRequest(local datatypes.RequestType, incoming datatypes.RequestType, peerList []string,
+	localID string) (datatypes.RequestType, bool) {
+
+	if !canAcceptRequest(local, incoming) {
+		return local, false
+	}
+	accepted := incoming
+	accepted.AwareList = addIfMissing(append([]string{}, incoming.AwareList...), localID) // sørger for at localID er med i AwareList
+
+	// sjekker at requesten er unassigned og at alle peers er aware of denne request:
+	if accepted.State == datatypes.Unassigned && isContainedIn(peerList, accepted.AwareList) {
+		accepted.State = datatypes.Assigned
+		accepted.AwareList = []string{localID}
+	}
+	return accepted, true
+}
+
+// gjør en Unassigned request om til Assigned dersom alle peers er aware av den. Brukes når en peer
+// forsvinner: da kan de gjenværende allerede være aware, og ingen ny melding vil utløse overgangen
+func promoteIfAllAware(request datatypes.RequestType, peerList []string, localID string) (datatypes.RequestType, bool) {
+	if request.State != datatypes.Unassigned || !isContainedIn(peerList, request.AwareList) {
+		return request, false
+	}
+	request.State = datatypes.Assigned
+	request.AwareList = []string{localID}
+	return request, true
+}
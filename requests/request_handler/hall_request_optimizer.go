@@ -0,0 +1,347 @@
+package requesthandler
+
+// Go-implementasjon av time-to-idle kostfunksjonen fra hall_request_assigner.
+// Simulerer alle heiser parallelt og gir hver ubetjente hall request til den heisen som når den først.
+
+import (
+	"sort"
+
+	"project/datatypes"
+)
+
+const (
+	TRAVEL_DURATION_MS    = 2500
+	DOOR_OPEN_DURATION_MS = 3000
+)
+
+type hallReq struct {
+	active     bool
+	assignedTo string
+}
+
+type simElev struct {
+	id          string
+	behaviour   datatypes.ElevBehaviour
+	floor       int
+	direction   datatypes.Direction
+	cabRequests []bool
+	time        int // simulert tid i ms
+}
+
+// regner ut hvilke hall requests hver heis skal ta, og legger til cab requests fra input
+func optimalHallRequests(input HRAInput) map[string][][datatypes.N_BUTTONS]bool {
+	numFloors := len(input.HallRequests)
+	reqs := make([][datatypes.N_HALL_BUTTONS]hallReq, numFloors)
+	for f := 0; f < numFloors; f++ {
+		for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
+			reqs[f][b].active = input.HallRequests[f][b]
+		}
+	}
+
+	ids := make([]string, 0, len(input.States))
+	for id := range input.States {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids) // sorterer på ID slik at alle noder kommer fram til samme fordeling
+
+	elevs := make([]simElev, 0, len(ids))
+	for _, id := range ids {
+		state := input.States[id]
+		elev := simElev{
+			id:          id,
+			behaviour:   sToBeh(state.Behavior),
+			floor:       state.Floor,
+			direction:   sToDir(state.Direction),
+			cabRequests: make([]bool, numFloors),
+		}
+		for f := 0; f < numFloors && f < len(state.CabRequests); f++ {
+			elev.cabRequests[f] = state.CabRequests[f]
+		}
+		if elev.floor < 0 || elev.floor >= numFloors {
+			continue // ugyldig etasje, heisen kan ikke være med i fordelingen
+		}
+		elevs = append(elevs, elev)
+	}
+
+	for i := range elevs {
+		performInitialMove(&elevs[i], reqs)
+	}
+
+	// uten noen heis i en gyldig etasje blir ingen hall requests fordelt, bare cab requests går videre
+	for len(elevs) > 0 {
+		sort.SliceStable(elevs, func(i, j int) bool { return elevs[i].time < elevs[j].time })
+
+		done := !anyUnassigned(reqs)
+		if unvisitedAreImmediatelyAssignable(reqs, elevs) {
+			assignImmediate(reqs, elevs)
+			done = true
+		}
+		if done {
+			break
+		}
+		performSingleMove(&elevs[0], reqs)
+	}
+
+	output := make(map[string][][datatypes.N_BUTTONS]bool)
+	for _, id := range ids {
+		orders := datatypes.NewOrders(numFloors)
+		for f := 0; f < numFloors; f++ {
+			for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
+				orders[f][b] = reqs[f][b].active && reqs[f][b].assignedTo == id
+			}
+			if f < len(input.States[id].CabRequests) {
+				orders[f][datatypes.BT_CAB] = input.States[id].CabRequests[f]
+			}
+		}
+		output[id] = orders
+	}
+	return output
+}
+
+func performInitialMove(elev *simElev, reqs [][datatypes.N_HALL_BUTTONS]hallReq) {
+	switch elev.behaviour {
+	case datatypes.DoorOpen:
+		elev.time += DOOR_OPEN_DURATION_MS / 2
+		fallthrough
+	case datatypes.Idle:
+		for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
+			if reqs[elev.floor][b].active {
+				reqs[elev.floor][b].assignedTo = elev.id
+				elev.time += DOOR_OPEN_DURATION_MS
+			}
+		}
+	case datatypes.Moving:
+		elev.floor = nextFloor(elev.floor, elev.direction, len(elev.cabRequests))
+		elev.time += TRAVEL_DURATION_MS / 2
+	}
+}
+
+func performSingleMove(elev *simElev, reqs [][datatypes.N_HALL_BUTTONS]hallReq) {
+	orders := elev.unassignedOrders(reqs)
+
+	switch elev.behaviour {
+	case datatypes.Moving:
+		if simShouldStop(orders, elev.floor, elev.direction) {
+			elev.behaviour = datatypes.DoorOpen
+			elev.time += DOOR_OPEN_DURATION_MS
+			elev.clearAtCurrentFloor(orders, reqs)
+		} else {
+			elev.floor = nextFloor(elev.floor, elev.direction, len(elev.cabRequests))
+			elev.time += TRAVEL_DURATION_MS
+		}
+	case datatypes.Idle, datatypes.DoorOpen:
+		elev.direction = simChooseDirection(orders, elev.floor, elev.direction)
+		if elev.direction == datatypes.DIR_STOP {
+			if anyOrdersAt(orders, elev.floor) {
+				elev.clearAtCurrentFloor(orders, reqs)
+				elev.time += DOOR_OPEN_DURATION_MS
+				elev.behaviour = datatypes.DoorOpen
+			} else {
+				elev.behaviour = datatypes.Idle
+			}
+		} else {
+			elev.behaviour = datatypes.Moving
+			elev.time += TRAVEL_DURATION_MS
+			elev.floor = nextFloor(elev.floor, elev.direction, len(elev.cabRequests))
+		}
+	}
+}
+
+// bestillingene heisen ser i simuleringen: egne cab requests og hall requests som ingen har tatt ennå
+func (elev *simElev) unassignedOrders(reqs [][datatypes.N_HALL_BUTTONS]hallReq) [][datatypes.N_BUTTONS]bool {
+	orders := datatypes.NewOrders(len(reqs))
+	for f := range reqs {
+		for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
+			orders[f][b] = reqs[f][b].active && reqs[f][b].assignedTo == ""
+		}
+		orders[f][datatypes.BT_CAB] = elev.cabRequests[f]
+	}
+	return orders
+}
+
+// fjerner bestillinger i etasjen i retningen heisen skal videre, hall requests gis til denne heisen
+func (elev *simElev) clearAtCurrentFloor(orders [][datatypes.N_BUTTONS]bool,
+	reqs [][datatypes.N_HALL_BUTTONS]hallReq) {
+
+	f := elev.floor
+	elev.cabRequests[f] = false
+
+	switch elev.direction {
+	case datatypes.DIR_UP:
+		if orders[f][datatypes.BT_HallUP] {
+			reqs[f][datatypes.BT_HallUP].assignedTo = elev.id
+		} else if !ordersAbove(orders, f) && orders[f][datatypes.BT_HallDOWN] {
+			reqs[f][datatypes.BT_HallDOWN].assignedTo = elev.id
+		}
+	case datatypes.DIR_DOWN:
+		if orders[f][datatypes.BT_HallDOWN] {
+			reqs[f][datatypes.BT_HallDOWN].assignedTo = elev.id
+		} else if !ordersBelow(orders, f) && orders[f][datatypes.BT_HallUP] {
+			reqs[f][datatypes.BT_HallUP].assignedTo = elev.id
+		}
+	case datatypes.DIR_STOP:
+		for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
+			if orders[f][b] {
+				reqs[f][b].assignedTo = elev.id
+			}
+		}
+	}
+}
+
+func anyUnassigned(reqs [][datatypes.N_HALL_BUTTONS]hallReq) bool {
+	for f := range reqs {
+		for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
+			if reqs[f][b].active && reqs[f][b].assignedTo == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sjekker om alle gjenværende hall requests kan gis direkte til en ledig heis som allerede står i etasjen
+func unvisitedAreImmediatelyAssignable(reqs [][datatypes.N_HALL_BUTTONS]hallReq, elevs []simElev) bool {
+	for _, elev := range elevs {
+		if anyCabRequests(elev) {
+			return false
+		}
+	}
+	for f := range reqs {
+		if reqs[f][datatypes.BT_HallUP].active && reqs[f][datatypes.BT_HallDOWN].active {
+			return false
+		}
+		for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
+			if !reqs[f][b].active || reqs[f][b].assignedTo != "" {
+				continue
+			}
+			found := false
+			for _, elev := range elevs {
+				if elev.floor == f && !anyCabRequests(elev) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func assignImmediate(reqs [][datatypes.N_HALL_BUTTONS]hallReq, elevs []simElev) {
+	for f := range reqs {
+		for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
+			for i := range elevs {
+				if reqs[f][b].active && reqs[f][b].assignedTo == "" &&
+					elevs[i].floor == f && !anyCabRequests(elevs[i]) {
+					reqs[f][b].assignedTo = elevs[i].id
+					elevs[i].time += DOOR_OPEN_DURATION_MS
+				}
+			}
+		}
+	}
+}
+
+func simShouldStop(orders [][datatypes.N_BUTTONS]bool, floor int, dir datatypes.Direction) bool {
+	switch dir {
+	case datatypes.DIR_DOWN:
+		return orders[floor][datatypes.BT_HallDOWN] || orders[floor][datatypes.BT_CAB] || !ordersBelow(orders, floor)
+	case datatypes.DIR_UP:
+		return orders[floor][datatypes.BT_HallUP] || orders[floor][datatypes.BT_CAB] || !ordersAbove(orders, floor)
+	}
+	return true
+}
+
+func simChooseDirection(orders [][datatypes.N_BUTTONS]bool, floor int, dir datatypes.Direction) datatypes.Direction {
+	switch dir {
+	case datatypes.DIR_UP:
+		if ordersAbove(orders, floor) {
+			return datatypes.DIR_UP
+		} else if anyOrdersAt(orders, floor) {
+			return datatypes.DIR_STOP
+		} else if ordersBelow(orders, floor) {
+			return datatypes.DIR_DOWN
+		}
+	case datatypes.DIR_DOWN, datatypes.DIR_STOP:
+		if ordersBelow(orders, floor) {
+			return datatypes.DIR_DOWN
+		} else if anyOrdersAt(orders, floor) {
+			return datatypes.DIR_STOP
+		} else if ordersAbove(orders, floor) {
+			return datatypes.DIR_UP
+		}
+	}
+	return datatypes.DIR_STOP
+}
+
+func ordersAbove(orders [][datatypes.N_BUTTONS]bool, floor int) bool {
+	for f := floor + 1; f < len(orders); f++ {
+		if anyOrdersAt(orders, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func ordersBelow(orders [][datatypes.N_BUTTONS]bool, floor int) bool {
+	for f := 0; f < floor; f++ {
+		if anyOrdersAt(orders, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyOrdersAt(orders [][datatypes.N_BUTTONS]bool, floor int) bool {
+	for b := 0; b < datatypes.N_BUTTONS; b++ {
+		if orders[floor][b] {
+			return true
+		}
+	}
+	return false
+}
+
+func anyCabRequests(elev simElev) bool {
+	for f := range elev.cabRequests {
+		if elev.cabRequests[f] {
+			return true
+		}
+	}
+	return false
+}
+
+// holder simulert etasje innenfor bygget, slik at en heis med feil retning ikke går utenfor
+func nextFloor(floor int, dir datatypes.Direction, numFloors int) int {
+	switch dir {
+	case datatypes.DIR_UP:
+		if floor < numFloors-1 {
+			return floor + 1
+		}
+	case datatypes.DIR_DOWN:
+		if floor > 0 {
+			return floor - 1
+		}
+	}
+	return floor
+}
+
+func sToDir(dir string) datatypes.Direction {
+	switch dir {
+	case "up":
+		return datatypes.DIR_UP
+	case "down":
+		return datatypes.DIR_DOWN
+	}
+	return datatypes.DIR_STOP
+}
+
+func sToBeh(beh string) datatypes.ElevBehaviour {
+	switch beh {
+	case "moving":
+		return datatypes.Moving
+	case "doorOpen":
+		return datatypes.DoorOpen
+	}
+	return datatypes.Idle
+}
@@ -0,0 +1,138 @@
+package requesthandler
+
+import (
+	"project/datatypes"
+	"reflect"
+	"testing"
+)
+
+// forventet fordeling følger time-to-idle kostfunksjonen i hall_request_assigner, README-eksempelet er kopiert derfra
+func TestOptimalHallRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    HRAInput
+		expected map[string][][datatypes.N_BUTTONS]bool
+	}{
+		{
+			// eksempelet fra README til hall_request_assigner
+			name: "readme example",
+			input: HRAInput{
+				HallRequests: [][datatypes.N_HALL_BUTTONS]bool{{false, false}, {true, false}, {false, false}, {false, true}},
+				States: map[string]HRAElevState{
+					"one": {Behavior: "moving", Floor: 2, Direction: "up", CabRequests: []bool{false, false, true, true}},
+					"two": {Behavior: "idle", Floor: 0, Direction: "stop", CabRequests: []bool{false, false, false, false}},
+				},
+			},
+			expected: map[string][][datatypes.N_BUTTONS]bool{
+				"one": {{false, false, false}, {false, false, false}, {false, false, true}, {false, true, true}},
+				"two": {{false, false, false}, {true, false, false}, {false, false, false}, {false, false, false}},
+			},
+		},
+		{
+			name: "one elevator takes everything",
+			input: HRAInput{
+				HallRequests: [][datatypes.N_HALL_BUTTONS]bool{{true, false}, {false, true}, {true, true}, {false, true}},
+				States: map[string]HRAElevState{
+					"one": {Behavior: "idle", Floor: 1, Direction: "stop", CabRequests: []bool{true, false, false, false}},
+				},
+			},
+			expected: map[string][][datatypes.N_BUTTONS]bool{
+				"one": {{true, false, true}, {false, true, false}, {true, true, false}, {false, true, false}},
+			},
+		},
+		{
+			name: "all idle without requests",
+			input: HRAInput{
+				HallRequests: [][datatypes.N_HALL_BUTTONS]bool{{false, false}, {false, false}, {false, false}, {false, false}},
+				States: map[string]HRAElevState{
+					"one":   {Behavior: "idle", Floor: 0, Direction: "stop", CabRequests: []bool{false, false, false, false}},
+					"two":   {Behavior: "idle", Floor: 2, Direction: "stop", CabRequests: []bool{false, false, false, false}},
+					"three": {Behavior: "idle", Floor: 3, Direction: "stop", CabRequests: []bool{false, false, false, false}},
+				},
+			},
+			expected: map[string][][datatypes.N_BUTTONS]bool{
+				"one":   {{false, false, false}, {false, false, false}, {false, false, false}, {false, false, false}},
+				"two":   {{false, false, false}, {false, false, false}, {false, false, false}, {false, false, false}},
+				"three": {{false, false, false}, {false, false, false}, {false, false, false}, {false, false, false}},
+			},
+		},
+		{
+			name: "all idle at the requested floors",
+			input: HRAInput{
+				HallRequests: [][datatypes.N_HALL_BUTTONS]bool{{true, false}, {false, false}, {false, false}, {false, true}},
+				States: map[string]HRAElevState{
+					"one": {Behavior: "idle", Floor: 3, Direction: "stop", CabRequests: []bool{false, false, false, false}},
+					"two": {Behavior: "idle", Floor: 0, Direction: "stop", CabRequests: []bool{false, false, false, false}},
+				},
+			},
+			expected: map[string][][datatypes.N_BUTTONS]bool{
+				"one": {{false, false, false}, {false, false, false}, {false, false, false}, {false, true, false}},
+				"two": {{true, false, false}, {false, false, false}, {false, false, false}, {false, false, false}},
+			},
+		},
+		{
+			// to like heiser i samme etasje. Simuleringen flytter dem annenhver gang, så vinneren avhenger bare
+			// av ID-ene og blir den samme på alle noder uansett rekkefølgen i States
+			name: "tie between identical elevators",
+			input: HRAInput{
+				HallRequests: [][datatypes.N_HALL_BUTTONS]bool{{false, false}, {false, false}, {true, false}, {false, false}},
+				States: map[string]HRAElevState{
+					"two": {Behavior: "idle", Floor: 0, Direction: "stop", CabRequests: []bool{false, false, false, false}},
+					"one": {Behavior: "idle", Floor: 0, Direction: "stop", CabRequests: []bool{false, false, false, false}},
+				},
+			},
+			expected: map[string][][datatypes.N_BUTTONS]bool{
+				"one": {{false, false, false}, {false, false, false}, {false, false, false}, {false, false, false}},
+				"two": {{false, false, false}, {false, false, false}, {true, false, false}, {false, false, false}},
+			},
+		},
+		{
+			name: "moving elevator takes request on its way",
+			input: HRAInput{
+				HallRequests: [][datatypes.N_HALL_BUTTONS]bool{{false, false}, {false, false}, {true, false}, {false, false}},
+				States: map[string]HRAElevState{
+					"one": {Behavior: "idle", Floor: 0, Direction: "stop", CabRequests: []bool{false, false, false, false}},
+					"two": {Behavior: "moving", Floor: 1, Direction: "up", CabRequests: []bool{false, false, false, true}},
+				},
+			},
+			expected: map[string][][datatypes.N_BUTTONS]bool{
+				"one": {{false, false, false}, {false, false, false}, {false, false, false}, {false, false, false}},
+				"two": {{false, false, false}, {false, false, false}, {true, false, false}, {false, false, true}},
+			},
+		},
+		{
+			name: "door open elevator keeps request at its floor",
+			input: HRAInput{
+				HallRequests: [][datatypes.N_HALL_BUTTONS]bool{{false, false}, {false, true}, {false, false}, {false, false}},
+				States: map[string]HRAElevState{
+					"one": {Behavior: "idle", Floor: 2, Direction: "stop", CabRequests: []bool{false, false, false, false}},
+					"two": {Behavior: "doorOpen", Floor: 1, Direction: "down", CabRequests: []bool{false, false, false, false}},
+				},
+			},
+			expected: map[string][][datatypes.N_BUTTONS]bool{
+				"one": {{false, false, false}, {false, false, false}, {false, false, false}, {false, false, false}},
+				"two": {{false, false, false}, {false, true, false}, {false, false, false}, {false, false, false}},
+			},
+		},
+		{
+			// etasjen kommer fra nettverket, én ugyldig statusmelding skal ikke krasje fordelingen
+			name: "only elevator at an invalid floor",
+			input: HRAInput{
+				HallRequests: [][datatypes.N_HALL_BUTTONS]bool{{false, false}, {true, false}, {false, false}, {false, false}},
+				States: map[string]HRAElevState{
+					"a": {Behavior: "idle", Floor: -1, Direction: "stop", CabRequests: []bool{false, false, true, false}},
+				},
+			},
+			expected: map[string][][datatypes.N_BUTTONS]bool{
+				"a": {{false, false, false}, {false, false, false}, {false, false, true}, {false, false, false}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		output := optimalHallRequests(test.input)
+		if !reflect.DeepEqual(output, test.expected) {
+			t.Errorf("%s: got %v, expected %v", test.name, output, test.expected)
+		}
+	}
+}
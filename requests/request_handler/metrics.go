@@ -0,0 +1,10 @@
+package requesthandler
+
+import "project/metrics"
+
+var (
+	assignerLatency = metrics.NewHistogram("elevator_assigner_duration_seconds", "Time spent in the hall request assigner.",
+		[]float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1})
+	assignerFailures = metrics.NewCounter("elevator_assigner_failures_total",
+		"Assignments where the local elevator was available but got no orders from the assigner.")
+)
@@ -0,0 +1,77 @@
+package requesthandler
+
+import (
+	"sort"
+
+	"project/datatypes"
+)
+
+// NearestCarAssigner gir hver hall request til heisen med kortest avstand til etasjen.
+// Heiser som allerede er på vei mot etasjen i riktig retning foretrekkes ved lik avstand
+type NearestCarAssigner struct{}
+
+func (NearestCarAssigner) Assign(
+	hallRequests [][datatypes.N_HALL_BUTTONS]datatypes.RequestType,
+	allCabRequests map[string][]datatypes.RequestType,
+	updatedInfoElevs map[string]datatypes.ElevatorInfo,
+	peerList []string) map[string][][datatypes.N_BUTTONS]bool {
+
+	input := buildHRAInput(hallRequests, allCabRequests, updatedInfoElevs, peerList)
+	output := map[string][][datatypes.N_BUTTONS]bool{}
+	if len(input.States) == 0 {
+		return output
+	}
+
+	ids := make([]string, 0, len(input.States))
+	for ID := range input.States {
+		ids = append(ids, ID)
+		output[ID] = datatypes.NewOrders(len(input.HallRequests))
+	}
+	sort.Strings(ids) // lik rekkefølge på alle noder gir lik fordeling
+
+	for floor := range input.HallRequests {
+		for button := 0; button < datatypes.N_HALL_BUTTONS; button++ {
+			if !input.HallRequests[floor][button] {
+				continue
+			}
+			bestID := ids[0]
+			bestCost := nearestCarCost(input.States[bestID], floor)
+			for _, ID := range ids[1:] {
+				if cost := nearestCarCost(input.States[ID], floor); cost < bestCost {
+					bestID, bestCost = ID, cost
+				}
+			}
+			orders := output[bestID]
+			orders[floor][button] = true
+			output[bestID] = orders
+		}
+	}
+
+	withCabRequests(input, output)
+	return output
+}
+
+// avstand i etasjer, ganget med to slik at en heis på vei mot etasjen kan vinne over en som står stille like langt unna
+func nearestCarCost(state HRAElevState, floor int) int {
+	distance := floor - state.Floor
+	if distance < 0 {
+		distance = -distance
+	}
+	cost := 2 * distance
+
+	switch sToDir(state.Direction) {
+	case datatypes.DIR_UP:
+		if floor < state.Floor {
+			cost++
+		} else if floor > state.Floor {
+			cost--
+		}
+	case datatypes.DIR_DOWN:
+		if floor > state.Floor {
+			cost++
+		} else if floor < state.Floor {
+			cost--
+		}
+	}
+	return cost
+}
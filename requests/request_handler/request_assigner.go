@@ -1,12 +1,14 @@
 package requesthandler
 
 import (
-	"encoding/json"
 	"fmt"
-	"os/exec"
 	"project/datatypes"
+	"project/logging"
+	"time"
 )
 
+var log = logging.New("assigner")
+
 type HRAElevState struct {
 	Behavior    string `json:"behaviour"`
 	Floor       int    `json:"floor"`
@@ -15,32 +17,106 @@ type HRAElevState struct {
 }
 
 type HRAInput struct {
-	HallRequests [datatypes.N_FLOORS][datatypes.N_HALL_BUTTONS]bool `json:"hallRequests"`
+	HallRequests [][datatypes.N_HALL_BUTTONS]bool `json:"hallRequests"`
 	States       map[string]HRAElevState                            `json:"states"`
 }
 
+// Assigner fordeler hall requests mellom heisene. Returnerer bestillingene til hver heis som er med i fordelingen
+type Assigner interface {
+	Assign(hallRequests [][datatypes.N_HALL_BUTTONS]datatypes.RequestType,
+		allCabRequests map[string][]datatypes.RequestType,
+		updatedInfoElevs map[string]datatypes.ElevatorInfo,
+		peerList []string) map[string][][datatypes.N_BUTTONS]bool
+}
+
+const (
+	ASSIGNER_TIME_TO_IDLE = "cost"
+	ASSIGNER_NEAREST_CAR  = "nearest"
+	ASSIGNER_ZONE         = "zone"
+)
+
+// lager en Assigner basert på navn, brukes av flagget i main
+func NewAssigner(name string) (Assigner, error) {
+	switch name {
+	case ASSIGNER_TIME_TO_IDLE:
+		return TimeToIdleAssigner{}, nil
+	case ASSIGNER_NEAREST_CAR:
+		return NearestCarAssigner{}, nil
+	case ASSIGNER_ZONE:
+		return ZoneAssigner{}, nil
+	}
+	return nil, fmt.Errorf("unknown assigner %q (valid: %s, %s, %s)",
+		name, ASSIGNER_TIME_TO_IDLE, ASSIGNER_NEAREST_CAR, ASSIGNER_ZONE)
+}
+
 func RequestAssigner(
-	hallRequests [datatypes.N_FLOORS][datatypes.N_HALL_BUTTONS]datatypes.RequestType,
-	allCabRequests map[string][datatypes.N_FLOORS]datatypes.RequestType,
+	assigner Assigner,
+	hallRequests [][datatypes.N_HALL_BUTTONS]datatypes.RequestType,
+	allCabRequests map[string][]datatypes.RequestType,
 	updatedInfoElevs map[string]datatypes.ElevatorInfo,
 	peerList []string,
-	localID string) [datatypes.N_FLOORS][datatypes.N_BUTTONS]bool {
+	localID string) [][datatypes.N_BUTTONS]bool {
 
-	fmt.Println("Start RequestAssigner")
-	fmt.Println("Mottatt hallRequests:", hallRequests)
-	fmt.Println("Mottatt allCabRequests:", allCabRequests)
-	fmt.Println("Mottatt updatedInfoElevs:", updatedInfoElevs)
-	fmt.Println("Mottatt peerList:", peerList)
-	fmt.Println("Mottatt localID:", localID)
+	// lokal heis skal alltid være med i fordelingen, selv om den ikke er i peerList
+	if !sliceContains(peerList, localID) {
+		peerList = append(append([]string{}, peerList...), localID)
+	}
 
-	HRAExecutablePath := "./hall_request_assigner"
+	start := time.Now()
+	output := assigner.Assign(hallRequests, allCabRequests, updatedInfoElevs, peerList)
+	assignerLatency.ObserveDuration(time.Since(start))
 
-	hallRequestsBool := [datatypes.N_FLOORS][datatypes.N_HALL_BUTTONS]bool{}
+	orders, included := output[localID]
+	if !included {
+		if updatedInfoElevs[localID].Available {
+			assignerFailures.Inc()
+			log.Warn("Assigner gave no orders to the available local elevator", "localID", localID, "peers", peerList)
+		}
+		// lokal heis er ikke med i fordelingen (f.eks. motorfeil), beholder bare egne cab requests
+		orders = datatypes.NewOrders(len(hallRequests))
+		for floor, cabRequest := range allCabRequests[localID] {
+			if floor < len(orders) && cabRequest.State == datatypes.Assigned {
+				orders[floor][datatypes.BT_CAB] = true
+			}
+		}
+	}
 
-	for floor := 0; floor < datatypes.N_FLOORS; floor++ {
-		for button := 0; button < datatypes.N_HALL_BUTTONS; button++ {
+	if log.Enabled(logging.DEBUG) {
+		log.Debug("Assigned requests", "localID", localID, "peers", peerList,
+			"hallRequests", hallRequests, "elevators", updatedInfoElevs, "orders", orders)
+	}
+	return orders
+}
+
+// TimeToIdleAssigner gir hver hall request til heisen som blir ferdig raskest, samme kostfunksjon som hall_request_assigner
+type TimeToIdleAssigner struct{}
+
+func (TimeToIdleAssigner) Assign(
+	hallRequests [][datatypes.N_HALL_BUTTONS]datatypes.RequestType,
+	allCabRequests map[string][]datatypes.RequestType,
+	updatedInfoElevs map[string]datatypes.ElevatorInfo,
+	peerList []string) map[string][][datatypes.N_BUTTONS]bool {
 
-			if button < datatypes.N_HALL_BUTTONS && hallRequests[floor][button].State == datatypes.Assigned {
+	input := buildHRAInput(hallRequests, allCabRequests, updatedInfoElevs, peerList)
+	if len(input.States) == 0 {
+		return map[string][][datatypes.N_BUTTONS]bool{}
+	}
+	return optimalHallRequests(input)
+}
+
+// lager felles input for alle strategiene: assigned hall requests og tilstanden til heisene som kan ta bestillinger
+func buildHRAInput(
+	hallRequests [][datatypes.N_HALL_BUTTONS]datatypes.RequestType,
+	allCabRequests map[string][]datatypes.RequestType,
+	updatedInfoElevs map[string]datatypes.ElevatorInfo,
+	peerList []string) HRAInput {
+
+	numFloors := len(hallRequests)
+	hallRequestsBool := make([][datatypes.N_HALL_BUTTONS]bool, numFloors)
+
+	for floor := 0; floor < numFloors; floor++ {
+		for button := 0; button < datatypes.N_HALL_BUTTONS; button++ {
+			if hallRequests[floor][button].State == datatypes.Assigned {
 				// hallRequestsBool skal gi en oversikt over requests som er assigned (true)
 				hallRequestsBool[floor][button] = true
 			}
@@ -57,14 +133,13 @@ func RequestAssigner(
 		if !elevatorINFO.Available {
 			continue
 		}
-
-		if !sliceContains(peerList, ID) && ID != localID { // sjekker om ID ikke er i peerlist, og sjekker at ID ikke er lik localID
+		if !sliceContains(peerList, ID) { // sjekker om ID ikke er i peerlist
 			continue
 		}
 
-		cabRequestsBool := [datatypes.N_FLOORS]bool{}
+		cabRequestsBool := make([]bool, numFloors)
 
-		for floor := 0; floor < datatypes.N_FLOORS; floor++ {
+		for floor := 0; floor < numFloors && floor < len(cabRequests); floor++ {
 			if cabRequests[floor].State == datatypes.Assigned {
 				cabRequestsBool[floor] = true
 			}
@@ -73,50 +148,25 @@ func RequestAssigner(
 			Behavior:    behToS(elevatorINFO.Behaviour),
 			Floor:       elevatorINFO.CurrentFloor,
 			Direction:   dirToS(elevatorINFO.Direction),
-			CabRequests: cabRequestsBool[:],
+			CabRequests: cabRequestsBool,
 		}
 	}
-	if len(inputStates) == 0 {
-		return [datatypes.N_FLOORS][datatypes.N_BUTTONS]bool{}
-	}
 
-	input := HRAInput{
+	return HRAInput{
 		HallRequests: hallRequestsBool,
 		States:       inputStates,
 	}
+}
 
-	jsonBytes, err := json.Marshal(input)
-	if err != nil {
-		fmt.Println("error with json.Marshal: ", err)
-		return [datatypes.N_FLOORS][datatypes.N_BUTTONS]bool{}
-	}
-
-	//fmt.Println("JSON Payload:", string(jsonBytes)) //debug
-	cmd := exec.Command(HRAExecutablePath, "-i", string(jsonBytes), "--includeCab")
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		fmt.Println("exec.Command error: ", err)
-		fmt.Println("Command output: ", string(out))
-		// returnerer en tom matrise dersom noe går galt
-		return [datatypes.N_FLOORS][datatypes.N_BUTTONS]bool{}
-	}
-
-	output := new(map[string][datatypes.N_FLOORS][datatypes.N_BUTTONS]bool)
-	err = json.Unmarshal(out, &output) // Unmarshal brukes til å dekode JSON-data fra en strøm (out) og lagre det i en go-variabel
-	if err != nil {
-		fmt.Println("json.Unmarshal error: ", err)
-		return [datatypes.N_FLOORS][datatypes.N_BUTTONS]bool{}
+// legger cab requests inn i bestillingene, felles for alle strategiene
+func withCabRequests(input HRAInput, output map[string][][datatypes.N_BUTTONS]bool) {
+	for ID, state := range input.States {
+		orders := output[ID]
+		for floor := 0; floor < len(orders) && floor < len(state.CabRequests); floor++ {
+			orders[floor][datatypes.BT_CAB] = state.CabRequests[floor]
+		}
+		output[ID] = orders
 	}
-
-	// fmt.Println(" RequestAssigner MOTTOK:")
-	// fmt.Println("   - hallRequests =", hallRequests)
-	// fmt.Println("   - allCabRequests =", allCabRequests)
-	// fmt.Println("   - updatedInfoElevs =", updatedInfoElevs)
-	// fmt.Println("   - peerList =", peerList)
-
-	fmt.Println("Final assigned hallRequests for", localID, ":", (*output)[localID])
-	return (*output)[localID] // dereferer pekeren, henter verdien av output, altså selve map objektet
-
 }
 
 func sliceContains(slice []string, elem string) bool { // skal returnere en boolsk verdi avhengig av om slicen inneholder elem
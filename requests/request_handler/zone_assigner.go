@@ -0,0 +1,54 @@
+package requesthandler
+
+import (
+	"sort"
+
+	"project/datatypes"
+)
+
+// ZoneAssigner deler bygget i like store soner, én per tilgjengelig heis, sortert på ID.
+// En hall request gis til heisen som eier sonen etasjen ligger i
+type ZoneAssigner struct{}
+
+func (ZoneAssigner) Assign(
+	hallRequests [][datatypes.N_HALL_BUTTONS]datatypes.RequestType,
+	allCabRequests map[string][]datatypes.RequestType,
+	updatedInfoElevs map[string]datatypes.ElevatorInfo,
+	peerList []string) map[string][][datatypes.N_BUTTONS]bool {
+
+	input := buildHRAInput(hallRequests, allCabRequests, updatedInfoElevs, peerList)
+	output := map[string][][datatypes.N_BUTTONS]bool{}
+	if len(input.States) == 0 {
+		return output
+	}
+
+	ids := make([]string, 0, len(input.States))
+	for ID := range input.States {
+		ids = append(ids, ID)
+		output[ID] = datatypes.NewOrders(len(input.HallRequests))
+	}
+	sort.Strings(ids)
+
+	for floor := range input.HallRequests {
+		owner := ids[zoneOf(floor, len(ids), len(input.HallRequests))]
+		for button := 0; button < datatypes.N_HALL_BUTTONS; button++ {
+			if !input.HallRequests[floor][button] {
+				continue
+			}
+			orders := output[owner]
+			orders[floor][button] = true
+			output[owner] = orders
+		}
+	}
+
+	withCabRequests(input, output)
+	return output
+}
+
+// finner hvilken sone etasjen hører til når bygget deles i nZones sammenhengende soner
+func zoneOf(floor int, nZones int, numFloors int) int {
+	if nZones > numFloors {
+		nZones = numFloors // flere heiser enn etasjer, de siste heisene får ingen sone
+	}
+	return floor * nZones / numFloors
+}
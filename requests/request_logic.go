@@ -5,7 +5,7 @@ import (
 )
 
 func RequestsAbove(elevator datatypes.Elevator) bool { // skal returnere true/false om det er noen aktive orders i etasjer over
-	for f := elevator.CurrentFloor + 1; f < datatypes.N_FLOORS; f++ {
+	for f := elevator.CurrentFloor + 1; f < len(elevator.Orders); f++ {
 		for _, order := range elevator.Orders[f] {
 			if order {
 				return true
@@ -41,7 +41,7 @@ func getReqTypeHere(elevator datatypes.Elevator) datatypes.ButtonType {
 			return datatypes.ButtonType(b)
 		}
 	}
-	print("buttontype not found")
+	log.Warn("No order at the current floor, defaulting to cab", "floor", elevator.CurrentFloor)
 	return datatypes.BT_CAB
 }
 
@@ -91,6 +91,10 @@ func ChooseNewDirAndBeh(elevator datatypes.Elevator) (datatypes.Direction, datat
 
 func ShouldStop(elevator datatypes.Elevator) bool {
 	currentFloor := elevator.CurrentFloor
+	if currentFloor == 0 || currentFloor == len(elevator.Orders)-1 {
+		// heisen kan ikke kjøre videre fra enden av sjakten, så den stopper uansett retning
+		return true
+	}
 	switch elevator.Direction {
 	case datatypes.DIR_DOWN:
 		return elevator.Orders[currentFloor][datatypes.BT_HallDOWN] || elevator.Orders[currentFloor][datatypes.BT_CAB] ||
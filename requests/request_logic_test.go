@@ -0,0 +1,364 @@
+package requests
+
+// egenskapsbasert testing av beslutningsfunksjonene. Tilfeldige heistilstander genereres, hver egenskap
+// sjekkes mot dem, og en tilstand som feiler krympes til den minste som fortsatt feiler
+
+import (
+	"fmt"
+	"math/rand"
+	"project/datatypes"
+	"strings"
+	"testing"
+)
+
+const (
+	PROPERTY_ITERATIONS       = 10000
+	PROPERTY_ITERATIONS_SHORT = 1000
+	PROPERTY_SEED             = 1
+	MAX_FLOORS                = 8
+	MIN_FLOORS                = 2
+	MAX_SHRINK_STEPS          = 1000
+)
+
+// gir en tom streng når egenskapen holder, ellers en beskrivelse av hva som gikk galt
+type elevatorProperty func(elevator datatypes.Elevator) string
+
+// sjekker egenskapen mot tilfeldige tilstander og feiler med den krympede tilstanden ved første feil
+func checkProperty(t *testing.T, property elevatorProperty) {
+	iterations := PROPERTY_ITERATIONS
+	if testing.Short() {
+		iterations = PROPERTY_ITERATIONS_SHORT
+	}
+	rng := rand.New(rand.NewSource(PROPERTY_SEED))
+	for i := 0; i < iterations; i++ {
+		elevator := generateElevator(rng, MAX_FLOORS)
+		if property(copyElevator(elevator)) == "" {
+			continue
+		}
+		shrunk, steps := shrink(elevator, property)
+		t.Fatalf("failed at iteration %d: %s\n  original:\n    %s  shrunk in %d steps to:\n    %s",
+			i, property(copyElevator(shrunk)), elevatorToS(elevator), steps, elevatorToS(shrunk))
+	}
+}
+
+// lager en tilfeldig heistilstand. Knapper som ikke finnes, hall up i øverste og hall down i
+// nederste etasje, er aldri satt
+func generateElevator(rng *rand.Rand, maxFloors int) datatypes.Elevator {
+	numFloors := MIN_FLOORS + rng.Intn(maxFloors-MIN_FLOORS+1)
+	elevator := datatypes.Elevator{
+		CurrentFloor: rng.Intn(numFloors),
+		Direction:    datatypes.Direction(rng.Intn(3)),
+		State:        datatypes.ElevBehaviour(rng.Intn(3)),
+		Orders:       datatypes.NewOrders(numFloors),
+	}
+	// tetthet varierer, slik at både nesten tomme og nesten fulle tabeller blir prøvd
+	density := rng.Float64()
+	for f := 0; f < numFloors; f++ {
+		for b := 0; b < datatypes.N_BUTTONS; b++ {
+			if buttonExists(f, b, numFloors) && rng.Float64() < density {
+				elevator.Orders[f][b] = true
+			}
+		}
+	}
+	return elevator
+}
+
+func buttonExists(floor int, button int, numFloors int) bool {
+	switch datatypes.ButtonType(button) {
+	case datatypes.BT_HallUP:
+		return floor < numFloors-1
+	case datatypes.BT_HallDOWN:
+		return floor > 0
+	}
+	return true
+}
+
+// prøver mindre varianter av en tilstand som feiler, og beholder den første som fortsatt feiler,
+// til ingen mindre variant feiler. Returnerer den minste tilstanden og antall steg
+func shrink(elevator datatypes.Elevator, property elevatorProperty) (datatypes.Elevator, int) {
+	steps := 0
+	for steps < MAX_SHRINK_STEPS {
+		smaller := false
+		for _, candidate := range shrinkCandidates(elevator) {
+			if property(copyElevator(candidate)) != "" {
+				elevator = candidate
+				smaller = true
+				steps++
+				break
+			}
+		}
+		if !smaller {
+			break
+		}
+	}
+	return elevator, steps
+}
+
+// mindre varianter, de som fjerner mest først: færre etasjer, færre bestillinger, lavere etasje,
+// enklere retning og tilstand
+func shrinkCandidates(elevator datatypes.Elevator) []datatypes.Elevator {
+	candidates := []datatypes.Elevator{}
+	numFloors := len(elevator.Orders)
+
+	if numFloors > MIN_FLOORS {
+		// fjerner en etasje, alle over flyttes ned
+		for f := numFloors - 1; f >= 0; f-- {
+			if f == elevator.CurrentFloor {
+				continue
+			}
+			candidate := copyElevator(elevator)
+			candidate.Orders = append(candidate.Orders[:f:f], candidate.Orders[f+1:]...)
+			if f < candidate.CurrentFloor {
+				candidate.CurrentFloor--
+			}
+			removeMissingButtons(&candidate)
+			candidates = append(candidates, candidate)
+		}
+	}
+	for f := 0; f < numFloors; f++ {
+		for b := 0; b < datatypes.N_BUTTONS; b++ {
+			if elevator.Orders[f][b] {
+				candidate := copyElevator(elevator)
+				candidate.Orders[f][b] = false
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+	if elevator.CurrentFloor > 0 {
+		candidate := copyElevator(elevator)
+		candidate.CurrentFloor--
+		candidates = append(candidates, candidate)
+	}
+	if elevator.Direction != datatypes.DIR_STOP {
+		candidate := copyElevator(elevator)
+		candidate.Direction = datatypes.DIR_STOP
+		candidates = append(candidates, candidate)
+	}
+	if elevator.State != datatypes.Idle {
+		candidate := copyElevator(elevator)
+		candidate.State = datatypes.Idle
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// når etasjer fjernes kan en knapp havne i en etasje der den ikke finnes
+func removeMissingButtons(elevator *datatypes.Elevator) {
+	numFloors := len(elevator.Orders)
+	for f := 0; f < numFloors; f++ {
+		for b := 0; b < datatypes.N_BUTTONS; b++ {
+			if !buttonExists(f, b, numFloors) {
+				elevator.Orders[f][b] = false
+			}
+		}
+	}
+}
+
+func copyElevator(elevator datatypes.Elevator) datatypes.Elevator {
+	elevator.Orders = append([][datatypes.N_BUTTONS]bool{}, elevator.Orders...)
+	return elevator
+}
+
+func elevatorToS(elevator datatypes.Elevator) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "floor %d of %d, direction %s, %s\n", elevator.CurrentFloor, len(elevator.Orders),
+		dirToS(elevator.Direction), behToS(elevator.State))
+	for f := len(elevator.Orders) - 1; f >= 0; f-- {
+		marker := " "
+		if f == elevator.CurrentFloor {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "    %s %d  up:%s down:%s cab:%s\n", marker, f,
+			orderToS(elevator.Orders[f][datatypes.BT_HallUP]),
+			orderToS(elevator.Orders[f][datatypes.BT_HallDOWN]),
+			orderToS(elevator.Orders[f][datatypes.BT_CAB]))
+	}
+	return b.String()
+}
+
+func orderToS(order bool) string {
+	if order {
+		return "x"
+	}
+	return "."
+}
+
+func dirToS(dir datatypes.Direction) string {
+	switch dir {
+	case datatypes.DIR_UP:
+		return "up"
+	case datatypes.DIR_DOWN:
+		return "down"
+	case datatypes.DIR_STOP:
+		return "stop"
+	}
+	return "unknown"
+}
+
+func behToS(beh datatypes.ElevBehaviour) string {
+	switch beh {
+	case datatypes.Idle:
+		return "idle"
+	case datatypes.Moving:
+		return "moving"
+	case datatypes.DoorOpen:
+		return "door open"
+	}
+	return "unknown"
+}
+
+func hasOrders(elevator datatypes.Elevator) bool {
+	for f := range elevator.Orders {
+		for b := 0; b < datatypes.N_BUTTONS; b++ {
+			if elevator.Orders[f][b] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// en heis med bestillinger blir aldri Idle
+func TestOrdersNeverIdle(t *testing.T) {
+	checkProperty(t, func(elevator datatypes.Elevator) string {
+		if !hasOrders(elevator) {
+			return ""
+		}
+		dir, beh := ChooseNewDirAndBeh(elevator)
+		if beh == datatypes.Idle {
+			return fmt.Sprintf("ChooseNewDirAndBeh gave idle (direction %s) with orders", dirToS(dir))
+		}
+		return ""
+	})
+}
+
+// ChooseNewDirAndBeh kjører aldri forbi øverste eller nederste etasje
+func TestStaysInShaft(t *testing.T) {
+	checkProperty(t, func(elevator datatypes.Elevator) string {
+		dir, beh := ChooseNewDirAndBeh(elevator)
+		if beh != datatypes.Moving {
+			return ""
+		}
+		top := len(elevator.Orders) - 1
+		if dir == datatypes.DIR_UP && elevator.CurrentFloor >= top {
+			return "moving up from the top floor"
+		}
+		if dir == datatypes.DIR_DOWN && elevator.CurrentFloor <= 0 {
+			return "moving down from the bottom floor"
+		}
+		if dir == datatypes.DIR_STOP {
+			return "moving without a direction"
+		}
+		return ""
+	})
+}
+
+// ShouldStop er alltid true i øverste og nederste etasje, uansett retning og bestillinger
+func TestStopsAtTerminalFloors(t *testing.T) {
+	checkProperty(t, func(elevator datatypes.Elevator) string {
+		top := len(elevator.Orders) - 1
+		if elevator.CurrentFloor == 0 && !ShouldStop(elevator) {
+			return fmt.Sprintf("ShouldStop is false at the bottom floor going %s", dirToS(elevator.Direction))
+		}
+		if elevator.CurrentFloor == top && !ShouldStop(elevator) {
+			return fmt.Sprintf("ShouldStop is false at the top floor going %s", dirToS(elevator.Direction))
+		}
+		return ""
+	})
+}
+
+// en cab-bestilling i etasjen gjør alltid at heisen stopper
+func TestStopsForCabHere(t *testing.T) {
+	checkProperty(t, func(elevator datatypes.Elevator) string {
+		if elevator.Orders[elevator.CurrentFloor][datatypes.BT_CAB] && !ShouldStop(elevator) {
+			return "ShouldStop is false with a cab order at the current floor"
+		}
+		return ""
+	})
+}
+
+// CanClear-funksjonene gir bare true for bestillinger som finnes
+func TestClearsOnlyExistingOrders(t *testing.T) {
+	checkProperty(t, func(elevator datatypes.Elevator) string {
+		here := elevator.Orders[elevator.CurrentFloor]
+		if CanClearHallUp(elevator) && !here[datatypes.BT_HallUP] {
+			return "CanClearHallUp without a hall up order"
+		}
+		if CanClearHallDown(elevator) && !here[datatypes.BT_HallDOWN] {
+			return "CanClearHallDown without a hall down order"
+		}
+		if CanClearCab(elevator) && !here[datatypes.BT_CAB] {
+			return "CanClearCab without a cab order"
+		}
+		return ""
+	})
+}
+
+// kjører heisen slik fsm gjør, uten nye bestillinger, og sjekker at alle bestillinger blir tatt
+func TestAllOrdersCleared(t *testing.T) {
+	checkProperty(t, func(elevator datatypes.Elevator) string {
+		numFloors := len(elevator.Orders)
+		if elevator.State == datatypes.Moving {
+			// en heis i bevegelse er alltid på vei mot en etasje i sjakten
+			if (elevator.Direction == datatypes.DIR_UP && elevator.CurrentFloor == numFloors-1) ||
+				(elevator.Direction == datatypes.DIR_DOWN && elevator.CurrentFloor == 0) ||
+				elevator.Direction == datatypes.DIR_STOP {
+				return ""
+			}
+		}
+		maxSteps := 4 * numFloors * (datatypes.N_BUTTONS + 1)
+		for step := 0; step < maxSteps; step++ {
+			if !hasOrders(elevator) && elevator.State != datatypes.Moving {
+				return ""
+			}
+			if message := simulateStep(&elevator); message != "" {
+				return fmt.Sprintf("%s after %d steps", message, step)
+			}
+		}
+		if hasOrders(elevator) {
+			return fmt.Sprintf("orders left after %d steps, ended at floor %d %s", maxSteps, elevator.CurrentFloor, behToS(elevator.State))
+		}
+		return ""
+	})
+}
+
+// ett steg som i fsm.RunElevFSM: en Idle heis får bestillingene, en heis i bevegelse når neste etasje,
+// og for en åpen dør går timeren ut
+func simulateStep(elevator *datatypes.Elevator) string {
+	switch elevator.State {
+	case datatypes.Idle:
+		elevator.Direction, elevator.State = ChooseNewDirAndBeh(*elevator)
+
+	case datatypes.Moving:
+		switch elevator.Direction {
+		case datatypes.DIR_UP:
+			elevator.CurrentFloor++
+		case datatypes.DIR_DOWN:
+			elevator.CurrentFloor--
+		}
+		if elevator.CurrentFloor < 0 || elevator.CurrentFloor >= len(elevator.Orders) {
+			return fmt.Sprintf("moved out of the shaft to floor %d", elevator.CurrentFloor)
+		}
+		if ShouldStop(*elevator) {
+			floor := elevator.CurrentFloor
+			if CanClearHallUp(*elevator) {
+				elevator.Orders[floor][datatypes.BT_HallUP] = false
+			}
+			if CanClearHallDown(*elevator) {
+				elevator.Orders[floor][datatypes.BT_HallDOWN] = false
+			}
+			if CanClearCab(*elevator) {
+				elevator.Orders[floor][datatypes.BT_CAB] = false
+			}
+			elevator.State = datatypes.DoorOpen
+		}
+
+	case datatypes.DoorOpen:
+		// doorOpenTimer tar alle bestillingene i etasjen
+		for b := 0; b < datatypes.N_BUTTONS; b++ {
+			elevator.Orders[elevator.CurrentFloor][b] = false
+		}
+		elevator.Direction, elevator.State = ChooseNewDirAndBeh(*elevator)
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package requests
+
+// metrikker for bestillingene. requestTimer ser på endringer i state mellom hver runde i RequestControlLoop,
+// slik at det ikke spiller noen rolle om endringen kom fra et knappetrykk, fsm eller en melding fra en peer
+
+import (
+	"project/datatypes"
+	"project/elevio"
+	"project/metrics"
+	"time"
+)
+
+var requestLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+
+var (
+	buttonPresses   = metrics.NewCounterVec("elevator_button_presses_total", "Button presses handled by the node, by button type.", "button")
+	timeToAssigned  = metrics.NewHistogram("elevator_request_time_to_assigned_seconds", "Time from a request becomes Unassigned until it is Assigned.", requestLatencyBuckets)
+	timeToCompleted = metrics.NewHistogram("elevator_request_time_to_completed_seconds", "Time from a request becomes Assigned until it is Completed (passenger wait).", requestLatencyBuckets)
+)
+
+func buttonToS(button elevio.ButtonType) string {
+	switch button {
+	case elevio.BT_HallUp:
+		return "hall_up"
+	case elevio.BT_HallDown:
+		return "hall_down"
+	}
+	return "cab"
+}
+
+type requestKey struct {
+	cab    bool
+	floor  int
+	button int
+}
+
+type requestTimer struct {
+	states     map[requestKey]datatypes.RequestState
+	unassigned map[requestKey]time.Time
+	assigned   map[requestKey]time.Time
+}
+
+func newRequestTimer() *requestTimer {
+	return &requestTimer{
+		states:     make(map[requestKey]datatypes.RequestState),
+		unassigned: make(map[requestKey]time.Time),
+		assigned:   make(map[requestKey]time.Time),
+	}
+}
+
+// observe sammenligner med forrige runde og måler tiden for hall requests og lokale cab requests
+func (t *requestTimer) observe(hallRequests [][datatypes.N_HALL_BUTTONS]datatypes.RequestType,
+	localCabRequests []datatypes.RequestType, now time.Time) {
+
+	for f := range hallRequests {
+		for b := 0; b < datatypes.N_HALL_BUTTONS; b++ {
+			t.update(requestKey{floor: f, button: b}, hallRequests[f][b].State, now)
+		}
+	}
+	for f := range localCabRequests {
+		t.update(requestKey{cab: true, floor: f, button: int(datatypes.BT_CAB)}, localCabRequests[f].State, now)
+	}
+}
+
+func (t *requestTimer) update(key requestKey, state datatypes.RequestState, now time.Time) {
+	prev, known := t.states[key]
+	t.states[key] = state
+	if !known {
+		// første runde, f.eks. cab requests fra journalen. Vet ikke når de startet
+		return
+	}
+	if state == prev {
+		return
+	}
+	switch state {
+	case datatypes.Unassigned:
+		t.unassigned[key] = now
+	case datatypes.Assigned:
+		// kan gå rett fra Completed til Assigned når noden er alene, da er tiden 0
+		start, ok := t.unassigned[key]
+		if !ok {
+			start = now
+		}
+		timeToAssigned.ObserveDuration(now.Sub(start))
+		delete(t.unassigned, key)
+		t.assigned[key] = now
+	case datatypes.Completed:
+		if start, ok := t.assigned[key]; ok {
+			timeToCompleted.ObserveDuration(now.Sub(start))
+		}
+		delete(t.unassigned, key)
+		delete(t.assigned, key)
+	}
+}
@@ -0,0 +1,32 @@
+package requests
+
+import (
+	"project/clock"
+	"project/datatypes"
+	"project/network/bcast"
+	"project/network/peers"
+)
+
+// Transport kobler RequestControlLoop til de andre nodene. Start kalles én gang, og skal sende alt som
+// kommer på send, levere mottatte meldinger på receive og peer-oppdateringer på peerUpdates
+type Transport interface {
+	Start(localID string, send <-chan datatypes.NetworkMsg, receive chan<- datatypes.NetworkMsg,
+		peerUpdates chan<- peers.PeerUpdate)
+}
+
+// UDPTransport er standard: heartbeats med peers og NetworkMsg med bcast, som UDP broadcast
+type UDPTransport struct {
+	PeerPort int
+	MsgPort  int
+	Clock    clock.Clock // brukes til heartbeats og timeout for peers, nil gir vanlig tid
+}
+
+func (t UDPTransport) Start(localID string, send <-chan datatypes.NetworkMsg, receive chan<- datatypes.NetworkMsg,
+	peerUpdates chan<- peers.PeerUpdate) {
+
+	clk := clock.OrReal(t.Clock)
+	go peers.ReceiverWithClock(t.PeerPort, peerUpdates, clk)
+	go peers.TransmitterWithClock(t.PeerPort, localID, nil, clk)
+	go bcast.Receiver(t.MsgPort, receive)
+	go bcast.Transmitter(t.MsgPort, send)
+}